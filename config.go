@@ -10,99 +10,324 @@
 //
 // time.Duration follows the same parsing rules as https://golang.org/pkg/time#ParseDuration
 //
+// Types that implement encoding.TextUnmarshaler, encoding.BinaryUnmarshaler or flag.Value parse their own values,
+// e.g. net.IP, url.URL, big.Int and time.Time.
+//
+// Any other type can be bound by registering a decoder for it with WithDecoder.
+//
 // If chaining multiple data sources, data sets are merged.
 //
 // Later values override previous values.
 //   config.From("dev.config").FromEnv().To(&c)
 //
+// Other data sources can be plugged in by implementing Source.
+//   config.From("dev.config").FromSource(mySource).FromEnv().To(&c)
+//
 // Unset values remain intact or as their native zero value: https://tour.golang.org/basics/12.
 //
+// Missing values are not errors, unless the field is tagged as required.
+//   DatabaseURL string `config:"DATABASE_URL,required"`
+//
+// Defaults can be declared with the default struct tag. They are parsed the same as any other value,
+// and used when no source provides the field, unless the field was already set, e.g. by pre-populating the struct.
+// They have no effect on nested structs, maps, or slices of structs.
+//   Port int `default:"8080"`
+//
 // Nested structs/subconfigs are delimited with double underscore.
 //   PARENT__CHILD
 //
+// The delimiters can be changed, and slice delimiters can be overridden per field with the sep struct tag.
+//   config.WithStructDelim(".").WithSliceDelim(",").FromEnv().To(&c)
+//   Hosts []string `sep:";"`
+//
+// Variables in values can be expanded once all sources are merged.
+//   config.From("dev.config").WithInterpolation(true).To(&c) // DATABASE_URL=postgres://${DB_HOST}:${DB_PORT:-5432}/app
+//
+// If a value is not set, but the same key suffixed with _FILE is, the trimmed contents of the file it names are used.
+// This is the convention for Docker and Kubernetes secrets.
+//   DATABASE_PASSWORD_FILE=/run/secrets/db_password
+//
+// Keys that do not match any field are ignored, unless Strict is set.
+//
+// Pointers are left nil unless a value is present for them, or for any field nested under them.
+// This distinguishes "not configured" from the zero value.
+//
+// Maps are populated from every key nested under them. As keys are case insensitive, map keys are always lower case.
+//   LABELS__TEAM=core
+//   LABELS__TIER=1
+//
+// Slices of structs are populated from indexed keys.
+//   UPSTREAMS__0__HOST=a
+//   UPSTREAMS__1__HOST=b
+//
 // Env vars map to struct fields case insensitively.
 // NOTE: Also true when using struct tags.
 package config
 
 import (
-	"bufio"
 	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
 const (
-	structTagKey = "config"
-	structDelim  = "__"
-	sliceDelim   = " "
+	structTagKey   = "config"
+	defaultTagKey  = "default"
+	sepTagKey      = "sep"
+	requiredOption = "required"
+	defaultSource  = "default"
+	fileSuffix     = "_file"
+	structDelim    = "__"
+	sliceDelim     = " "
 )
 
 // Builder contains the current configuration state.
 type Builder struct {
 	structDelim, sliceDelim string
 	configMap               map[string]string
-	failedFields            []string
+	origins                 map[string][]Origin // every origin of each key, the last of which is in effect
+	defaulted               map[string]bool     // keys whose default struct tag was used
+	lists                   map[string][]string // elements of values that are lists, e.g. JSON arrays, so that they are never split
+	decoders                map[reflect.Type]DecodeFunc
+	interpolate             bool
+	interpolateEnv          bool
+	strict                  bool
+	sources                 []Source        // every source merged so far, in order, so that they can be read again
+	checked                 map[string]bool // keys from sources that Strict checks
+	flagSet                 *flag.FlagSet   // set by FromFlags, and merged by To once the target's fields are known
+	flagArgs                []string
+	flagsMerged             bool
+	known, used             map[string]bool // keys of fields, and keys that were bound to fields
+	sourceFailures          []*FieldError   // failures reading sources, which every call to To reports
+	failedFields            []*FieldError
 }
 
 func newBuilder() *Builder {
 	return &Builder{
 		configMap:   make(map[string]string),
+		origins:     make(map[string][]Origin),
+		defaulted:   make(map[string]bool),
+		lists:       make(map[string][]string),
+		checked:     make(map[string]bool),
+		known:       make(map[string]bool),
+		used:        make(map[string]bool),
+		decoders:    make(map[reflect.Type]DecodeFunc),
 		structDelim: structDelim,
 		sliceDelim:  sliceDelim,
 	}
 }
 
+// WithStructDelim returns a new Builder that delimits nested structs with delim.
+func WithStructDelim(delim string) *Builder {
+	return newBuilder().WithStructDelim(delim)
+}
+
+// WithStructDelim sets the delimiter between the keys of nested structs, returning the Builder.
+// The default is a double underscore, e.g. PARENT__CHILD.
+// Sources that build nested keys themselves, e.g. FromJSON, use the delimiter in effect when they are added.
+// It panics if delim is empty.
+func (c *Builder) WithStructDelim(delim string) *Builder {
+	if delim == "" {
+		panic("config: WithStructDelim(delim) must not be empty")
+	}
+	c.structDelim = delim
+	return c
+}
+
+// WithSliceDelim returns a new Builder that delimits slice values with delim.
+func WithSliceDelim(delim string) *Builder {
+	return newBuilder().WithSliceDelim(delim)
+}
+
+// WithSliceDelim sets the delimiter between slice values, returning the Builder.
+// The default is a space. Individual fields can override it with the sep struct tag, e.g. `sep:","`
+// Sources that join values themselves, e.g. FromJSON, use the delimiter in effect when they are added.
+// It panics if delim is empty.
+func (c *Builder) WithSliceDelim(delim string) *Builder {
+	if delim == "" {
+		panic("config: WithSliceDelim(delim) must not be empty")
+	}
+	c.sliceDelim = delim
+	return c
+}
+
+// DecodeFunc converts a string into a value of the type it is registered for with WithDecoder.
+// To prevent accidental logging of secrets, the returned error is never reported, only that decoding failed.
+type DecodeFunc func(string) (interface{}, error)
+
+// WithDecoder returns a new Builder that decodes values of type t with fn.
+func WithDecoder(t reflect.Type, fn DecodeFunc) *Builder {
+	return newBuilder().WithDecoder(t, fn)
+}
+
+// WithDecoder registers fn to decode values of type t, returning the Builder.
+// Registered decoders take precedence over all other conversions, including for slice elements.
+// This allows binding types you do not own, e.g.
+//   config.WithDecoder(reflect.TypeOf(time.Time{}), func(s string) (interface{}, error) {
+//       return time.Parse("2006-01-02", s)
+//   })
+func (c *Builder) WithDecoder(t reflect.Type, fn DecodeFunc) *Builder {
+	c.decoders[t] = fn
+	return c
+}
+
 // To accepts a struct pointer, and populates it with the current config state.
 // Supported fields:
 //     * all int, uint, float variants
 //     * bool, struct, string
 //     * time.Duration
-//     * slice of any of the above, except for []struct{}
-// It returns an error if:
-//     * struct contains unsupported fields (pointers, maps, slice of structs, channels, arrays, funcs, interfaces, complex)
+//     * encoding.TextUnmarshaler, encoding.BinaryUnmarshaler, flag.Value
+//     * any type with a decoder registered by WithDecoder
+//     * slice or array of any of the above. Arrays must be given exactly as many values as their length.
+//     * pointer to any of the above. Pointers are left nil unless a value is present for them.
+//     * map of any of the above, keyed by any of the above
+// It returns a *FieldErrors if:
+//     * a field tagged as required was not provided by any source
+//     * struct contains unsupported fields (channels, funcs, interfaces, complex)
 //     * there were errors doing file i/o
+//     * the args given to FromFlags could not be parsed
+//     * Strict is set, and a source provided a key that did not match any field
 // It panics if:
 //     * target is not a struct pointer
 func (c *Builder) To(target interface{}) error {
-	structPtr := reflect.ValueOf(target)
-	if structPtr.Kind() != reflect.Ptr || structPtr.Elem().Kind() != reflect.Struct {
-		panic("config: To(target) must be a *struct")
+	t := structPtrType(target, "To")
+	if c.flagSet != nil && !c.flagsMerged {
+		c.mergeFlags(t)
+	}
+	c.known, c.used, c.defaulted = make(map[string]bool), make(map[string]bool), make(map[string]bool)
+	c.failedFields = append([]*FieldError(nil), c.sourceFailures...) // so that calling To again does not report them twice
+	c.populateStructRecursively(reflect.ValueOf(target), "", "")
+	if c.strict {
+		c.checkUnknownKeys()
 	}
-	c.populateStructRecursively(structPtr, "")
 	if c.failedFields != nil {
-		return fmt.Errorf("config: the following fields had errors: %v", c.failedFields)
+		return &FieldErrors{Errors: c.failedFields}
 	}
 	return nil
 }
 
-// From returns a new Builder, populated with the values from file.
-func From(file string) *Builder {
-	return newBuilder().From(file)
+// structPtrType returns the type target points to.
+// It panics if target is not a struct pointer, naming fn as the function it was passed to.
+func structPtrType(target interface{}, fn string) reflect.Type {
+	structPtr := reflect.ValueOf(target)
+	if structPtr.Kind() != reflect.Ptr || structPtr.Elem().Kind() != reflect.Struct {
+		panic("config: " + fn + "(target) must be a *struct")
+	}
+	return structPtr.Elem().Type()
 }
 
-// From merges new values from file into the current config state, returning the Builder.
-func (c *Builder) From(file string) *Builder {
-	content, err := ioutil.ReadFile(file)
+// Source provides configuration values to a Builder.
+//
+// Values returns a map of keys to values.
+// Keys are matched to struct fields case insensitively, and empty values are ignored.
+// Any values returned alongside an error are still merged.
+// Returning a *SyntaxError reports the failure as a SyntaxFailure, otherwise it is an IOFailure.
+//
+// If a Source implements fmt.Stringer, it is used to name the source in errors.
+type Source interface {
+	Values() (map[string]string, error)
+}
+
+// FromSource returns a new Builder, populated with the values from s.
+func FromSource(s Source) *Builder {
+	return newBuilder().FromSource(s)
+}
+
+// FromSource merges new values from s into the current config state, returning the Builder.
+func (c *Builder) FromSource(s Source) *Builder {
+	c.sources = append(c.sources, s)
+	name := sourceName(s)
+	var (
+		values map[string]string
+		lines  map[string]int
+		lists  map[string][]string
+		err    error
+	)
+	switch ls := s.(type) {
+	case lineSource:
+		values, lines, err = ls.valuesWithLines()
+	case listSource:
+		values, lists, err = ls.valuesWithLists()
+	default:
+		values, err = s.Values()
+	}
 	if err != nil {
-		c.failedFields = append(c.failedFields, fmt.Sprintf("file[%v]", file))
+		kind := IOFailure
+		var syntaxErr *SyntaxError
+		if errors.As(err, &syntaxErr) {
+			kind = SyntaxFailure
+		}
+		c.sourceFailures = append(c.sourceFailures, &FieldError{Key: name, Source: name, Kind: kind, Err: err})
 	}
-	scanner := bufio.NewScanner(bytes.NewReader(content))
-	var ss []string
-	for scanner.Scan() {
-		ss = append(ss, scanner.Text())
+	c.mergeConfig(name, values, lines)
+	for k, list := range lists {
+		if values[k] != "" {
+			c.lists[strings.ToLower(k)] = list
+		}
 	}
-	if scanner.Err() != nil {
-		c.failedFields = append(c.failedFields, fmt.Sprintf("file[%v]", file))
+	if env, ok := s.(envSource); !ok || env.prefix != "" {
+		for k, v := range values {
+			if k != "" && v != "" {
+				c.checked[strings.ToLower(k)] = true
+			}
+		}
 	}
-	c.mergeConfig(stringsToMap(ss))
 	return c
 }
 
+// From returns a new Builder, populated with the values from file.
+func From(file string) *Builder {
+	return newBuilder().From(file)
+}
+
+// From merges new values from file into the current config state, returning the Builder.
+// file is in the dotenv format used by docker-compose and direnv, i.e. KEY=VALUE lines.
+// Comments, the export keyword, and single or double quoted values are supported.
+// Syntax errors are reported with the file name and line number.
+func (c *Builder) From(file string) *Builder {
+	return c.FromSource(fileSource(file))
+}
+
+// FromJSON returns a new Builder, populated with the values from the JSON document in file.
+func FromJSON(file string) *Builder {
+	return newBuilder().FromJSON(file)
+}
+
+// FromJSON merges new values from the JSON document in file into the current config state, returning the Builder.
+// The document must be a single JSON object. Anything else, including trailing data, is reported as a SyntaxFailure.
+// Nested objects are flattened into keys delimited the same as nested structs, e.g. {"parent": {"child": 1}} is PARENT__CHILD.
+// Arrays bind to slices and arrays element by element, regardless of delimiters or sep struct tags,
+// e.g. {"ips": ["0.0.0.0", "1.1.1.1"]} binds to IPs []net.IP
+// Arrays of objects are indexed the same as slices of structs, e.g. {"upstreams": [{"host": "a"}]} is UPSTREAMS__0__HOST
+func (c *Builder) FromJSON(file string) *Builder {
+	return c.FromSource(jsonSource{file: file, structDelim: c.structDelim, sliceDelim: c.sliceDelim})
+}
+
+// FromDir returns a new Builder, populated with the files in dir.
+func FromDir(dir string) *Builder {
+	return newBuilder().FromDir(dir)
+}
+
+// FromDir merges new values from the files in dir into the current config state, returning the Builder.
+// Each file name is a key, and its trimmed contents are the value.
+// Nested directories are delimited the same as nested structs, e.g. parent/child is PARENT__CHILD.
+// This is the layout of mounted Kubernetes ConfigMap and Secret volumes.
+// Symlinks are followed, and entries starting with .. are skipped, e.g. ..data
+func (c *Builder) FromDir(dir string) *Builder {
+	return c.FromSource(dirSource{dir: dir, structDelim: c.structDelim})
+}
+
 // FromEnv returns a new Builder, populated with environment variables
 func FromEnv() *Builder {
 	return newBuilder().FromEnv()
@@ -110,14 +335,242 @@ func FromEnv() *Builder {
 
 // FromEnv merges new values from the environment into the current config state, returning the Builder.
 func (c *Builder) FromEnv() *Builder {
-	c.mergeConfig(stringsToMap(os.Environ()))
-	return c
+	return c.FromSource(envSource{})
+}
+
+// FromEnvWithPrefix returns a new Builder, populated with environment variables that start with prefix.
+func FromEnvWithPrefix(prefix string) *Builder {
+	return newBuilder().FromEnvWithPrefix(prefix)
+}
+
+// FromEnvWithPrefix merges new values from environment variables that start with prefix into the current config state,
+// returning the Builder.
+// All other environment variables are ignored, and prefix is stripped before matching, e.g. MYAPP_PORT is PORT.
+// Like keys, prefix is matched case insensitively.
+func (c *Builder) FromEnvWithPrefix(prefix string) *Builder {
+	return c.FromSource(envSource{prefix: prefix})
 }
 
-func (c *Builder) mergeConfig(in map[string]string) {
+// mergeConfig merges in into the current config state, recording source and the line, if known, as the origin of each value.
+func (c *Builder) mergeConfig(source string, in map[string]string, lines map[string]int) {
 	for k, v := range in {
-		c.configMap[k] = v
+		if k != "" && v != "" {
+			lower := strings.ToLower(k)
+			c.configMap[lower] = v
+			delete(c.lists, lower)
+			c.origins[lower] = append(c.origins[lower], Origin{Source: source, Line: lines[k]})
+		}
+	}
+}
+
+// sourceName returns the name used to identify s in errors.
+func sourceName(s Source) string {
+	if stringer, ok := s.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprintf("%T", s)
+}
+
+// fileSource reads a file in the dotenv format, i.e. KEY=VALUE lines.
+type fileSource string
+
+func (f fileSource) Values() (map[string]string, error) {
+	values, _, err := f.valuesWithLines()
+	return values, err
+}
+
+func (f fileSource) valuesWithLines() (map[string]string, map[string]int, error) {
+	content, err := ioutil.ReadFile(string(f))
+	if err != nil {
+		return nil, nil, err
+	}
+	return parseDotenv(string(f), string(content))
+}
+
+func (f fileSource) String() string {
+	return fmt.Sprintf("file[%v]", string(f))
+}
+
+// listSource is implemented by sources whose values can be lists.
+type listSource interface {
+	Source
+	// valuesWithLists is Values, along with the elements of each value that is a list.
+	valuesWithLists() (values map[string]string, lists map[string][]string, err error)
+}
+
+// jsonSource reads a JSON object from a file, flattening it into keys.
+type jsonSource struct {
+	file, structDelim, sliceDelim string
+}
+
+func (j jsonSource) Values() (map[string]string, error) {
+	values, _, err := j.valuesWithLists()
+	return values, err
+}
+
+// valuesWithLists also returns the elements of each array of scalars, which are joined by the slice delimiter in values.
+func (j jsonSource) valuesWithLists() (map[string]string, map[string][]string, error) {
+	content, err := ioutil.ReadFile(j.file)
+	if err != nil {
+		return nil, nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber() // preserves the original formatting of numbers, e.g. large ints
+	var doc map[string]interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		// the messages of encoding/json are not used, as they can quote the offending value
+		switch err := err.(type) {
+		case *json.SyntaxError:
+			return nil, nil, j.syntaxError(content, err.Offset, "invalid JSON")
+		case *json.UnmarshalTypeError:
+			return nil, nil, j.syntaxError(content, err.Offset, "expected a JSON object")
+		default: // i.e. the document is empty or truncated
+			return nil, nil, j.syntaxError(content, int64(len(content)), "unexpected end of JSON")
+		}
+	}
+	end := decoder.InputOffset()
+	if rest := content[end:]; len(bytes.TrimSpace(rest)) != 0 {
+		start := end + int64(len(rest)-len(bytes.TrimLeft(rest, " \t\r\n")))
+		return nil, nil, j.syntaxError(content, start, "unexpected data after JSON object")
 	}
+	m, lists := make(map[string]string), make(map[string][]string)
+	j.flatten(m, lists, "", doc)
+	return m, lists, nil
+}
+
+// syntaxError returns a *SyntaxError with msg, on the line of content that offset is on.
+func (j jsonSource) syntaxError(content []byte, offset int64, msg string) *SyntaxError {
+	return &SyntaxError{File: j.file, Line: 1 + bytes.Count(content[:offset], []byte("\n")), Msg: msg}
+}
+
+// flatten adds each value of obj to m, keyed by its path from the root object.
+// The elements of arrays of scalars are also added to lists.
+func (j jsonSource) flatten(m map[string]string, lists map[string][]string, prefix string, obj map[string]interface{}) {
+	for k, v := range obj {
+		key := prefix + k
+		switch v := v.(type) {
+		case map[string]interface{}:
+			j.flatten(m, lists, key+j.structDelim, v)
+		case []interface{}:
+			var ss []string
+			for i, elem := range v {
+				if obj, ok := elem.(map[string]interface{}); ok {
+					j.flatten(m, lists, fmt.Sprintf("%v%v%v%v", key, j.structDelim, i, j.structDelim), obj)
+				} else if s, ok := jsonScalar(elem); ok {
+					ss = append(ss, s)
+				}
+			}
+			if ss != nil {
+				m[key], lists[key] = strings.Join(ss, j.sliceDelim), ss
+			}
+		default:
+			if s, ok := jsonScalar(v); ok {
+				m[key] = s
+			}
+		}
+	}
+}
+
+func (j jsonSource) String() string {
+	return fmt.Sprintf("file[%v]", j.file)
+}
+
+// dirSource reads each file in a directory as a value, keyed by its path relative to the directory.
+type dirSource struct {
+	dir, structDelim string
+}
+
+func (d dirSource) Values() (map[string]string, error) {
+	m := make(map[string]string)
+	err := d.read(m, d.dir, "", make(map[string]bool))
+	return m, err
+}
+
+// read adds each file in dir to m, recursing into nested directories.
+// visited holds the real paths of dir's ancestors, to avoid symlink loops.
+// Other directories may be read more than once, e.g. when sibling symlinks point to the same directory.
+// The first error is returned, but all files are attempted.
+func (d dirSource) read(m map[string]string, dir, prefix string, visited map[string]bool) error {
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	if visited[realDir] {
+		return nil
+	}
+	visited[realDir] = true
+	defer delete(visited, realDir)
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "..") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path) // follows symlinks, unlike entry
+		if err == nil && info.IsDir() {
+			err = d.read(m, path, prefix+entry.Name()+d.structDelim, visited)
+		} else if err == nil {
+			var content []byte
+			content, err = ioutil.ReadFile(path)
+			m[prefix+entry.Name()] = strings.TrimSpace(string(content))
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (d dirSource) String() string {
+	return fmt.Sprintf("dir[%v]", d.dir)
+}
+
+// jsonScalar returns the string form of a decoded JSON string, number or bool.
+// null, objects and arrays are not scalars.
+func jsonScalar(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		return "", false
+	}
+}
+
+// envSource reads the environment of the current process.
+// If prefix is set, only variables that start with it are read, and it is stripped from their keys.
+type envSource struct {
+	prefix string
+}
+
+func (e envSource) Values() (map[string]string, error) {
+	m := stringsToMap(os.Environ())
+	if e.prefix == "" {
+		return m, nil
+	}
+	prefix := strings.ToLower(e.prefix)
+	scoped := make(map[string]string)
+	for k, v := range m {
+		if strings.HasPrefix(k, prefix) {
+			scoped[strings.TrimPrefix(k, prefix)] = v
+		}
+	}
+	return scoped, nil
+}
+
+func (e envSource) String() string {
+	if e.prefix == "" {
+		return "env"
+	}
+	return fmt.Sprintf("env[%v]", e.prefix)
 }
 
 // stringsToMap builds a map from a string slice.
@@ -139,33 +592,322 @@ func stringsToMap(ss []string) map[string]string {
 }
 
 // populateStructRecursively populates each field of the passed in struct.
-// slices and values are set directly.
-// nested structs recurse through this function.
 // values are derived from the field name, prefixed with the field names of any parents.
+// path is the same prefix in terms of Go field names, used for error reporting.
 //
 // failed fields are added to the builder for error reporting
-func (c *Builder) populateStructRecursively(structPtr reflect.Value, prefix string) {
+func (c *Builder) populateStructRecursively(structPtr reflect.Value, prefix, path string) {
 	structValue := structPtr.Elem()
-	for i := 0; i < structValue.NumField(); i++ {
-		fieldType := structValue.Type().Field(i)
-		fieldPtr := structValue.Field(i).Addr()
-
-		key := getKey(fieldType, prefix)
-		value := c.configMap[key]
-
-		switch fieldType.Type.Kind() {
-		case reflect.Struct:
-			c.populateStructRecursively(fieldPtr, key+c.structDelim)
-		case reflect.Slice:
-			for _, index := range convertAndSetSlice(fieldPtr, stringToSlice(value, c.sliceDelim)) {
-				c.failedFields = append(c.failedFields, fmt.Sprintf("%v[%v]", key, index))
-			}
-		default:
-			if !convertAndSetValue(fieldPtr, value) {
-				c.failedFields = append(c.failedFields, key)
+	walkStruct(structValue.Type(), prefix, path, func(i int, sf reflect.StructField, key, field string) {
+		c.populate(structValue.Field(i).Addr(), sf, key, field)
+	})
+}
+
+// walkStruct calls fn with the index, key and Go field path of each field of the struct type t.
+// Keys are prefixed with prefix, and paths with path.
+// Unexported fields are skipped, as they can neither be set nor read.
+func walkStruct(t reflect.Type, prefix, path string, fn func(i int, sf reflect.StructField, key, field string)) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		fn(i, sf, getKey(sf, prefix), path+sf.Name)
+	}
+}
+
+// kindOf returns the kind of t that determines how it is walked.
+// Scalars are reflect.Invalid, as they are set directly regardless of their kind.
+func (c *Builder) kindOf(t reflect.Type) reflect.Kind {
+	if c.isScalar(t) {
+		return reflect.Invalid
+	}
+	return t.Kind()
+}
+
+// populate populates the value ptr points to, which is sf or what sf points to, from key.
+// slices and values are set directly.
+// nested structs recurse through populateStructRecursively.
+// pointers are only allocated if key, or any key nested under it, is present.
+func (c *Builder) populate(ptr reflect.Value, sf reflect.StructField, key, field string) {
+	t := ptr.Elem().Type()
+	value, source, failure := c.lookup(key, sf, ptr.Elem())
+	if failure != nil {
+		failure.Key, failure.Field, failure.Type, failure.Source = key, field, t, source
+		c.failedFields = append(c.failedFields, failure)
+		return
+	}
+
+	if _, opts := parseTag(sf); opts.Contains(requiredOption) && c.isMissing(ptr, key, source) {
+		if !c.isNested(t) {
+			c.known[key] = true
+		}
+		c.failedFields = append(c.failedFields, &FieldError{Key: key, Field: field, Type: t, Kind: MissingValue})
+		return
+	}
+
+	switch c.kindOf(t) {
+	case reflect.Struct:
+		c.populateStructRecursively(ptr, key+c.structDelim, field+".")
+	case reflect.Ptr:
+		if ptr.Elem().IsNil() {
+			if source == "" && !c.hasPrefix(key+c.structDelim) {
+				return
 			}
+			ptr.Elem().Set(reflect.New(t.Elem()))
+		}
+		c.populate(ptr.Elem(), sf, key, field)
+	case reflect.Slice:
+		if c.isNested(t.Elem()) {
+			c.populateStructSlice(ptr, sf, key, field)
+			return
+		}
+		c.known[key] = true
+		for _, index := range convertAndSetSlice(ptr, c.split(key, value, sf), c.setValue) {
+			c.fail(fmt.Sprintf("%v[%v]", key, index), fmt.Sprintf("%v[%v]", field, index), t.Elem(), source)
+		}
+	case reflect.Array:
+		c.known[key] = true
+		values := c.split(key, value, sf)
+		if values == nil {
+			return
+		}
+		if len(values) != t.Len() {
+			c.failedFields = append(c.failedFields, &FieldError{Key: key, Field: field, Type: t, Source: source, Kind: ParseFailure})
+			return
+		}
+		for _, index := range convertAndSetArray(ptr, values, c.setValue) {
+			c.fail(fmt.Sprintf("%v[%v]", key, index), fmt.Sprintf("%v[%v]", field, index), t.Elem(), source)
+		}
+	case reflect.Map:
+		c.populateMap(ptr, sf, key, field)
+	default:
+		c.known[key] = true
+		if !c.setValue(ptr, value) {
+			c.fail(key, field, t, source)
+		}
+	}
+}
+
+// populateMap populates the map ptr points to with every key nested under key.
+// The remainder of each key is the map key, e.g. LABELS__TEAM is labels["team"].
+// If the map's values are nested structs, only the first segment of the remainder is used,
+// e.g. TENANTS__ACME__PORT is tenants["acme"].Port
+// Map keys are always literal, as they are not known ahead of time, so the _FILE suffix does not apply to them,
+// e.g. PATHS__LOG_FILE is paths["log_file"]. It does apply to the fields of nested structs, e.g. TENANTS__ACME__PASSWORD_FILE.
+// The map remains nil if no keys are nested under key.
+func (c *Builder) populateMap(ptr reflect.Value, sf reflect.StructField, key, field string) {
+	mapValue, t := ptr.Elem(), ptr.Elem().Type()
+	prefix := key + c.structDelim
+
+	var mapKeys []string
+	seen := make(map[string]bool)
+	for k := range c.configMap {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		mapKey := strings.TrimPrefix(k, prefix)
+		if c.isNested(t.Elem()) {
+			mapKey = strings.SplitN(mapKey, c.structDelim, 2)[0]
+		}
+		if mapKey != "" && !seen[mapKey] {
+			seen[mapKey] = true
+			mapKeys = append(mapKeys, mapKey)
+		}
+	}
+	sort.Strings(mapKeys)
+
+	for _, mapKey := range mapKeys {
+		elemKey, elemField := prefix+mapKey, fmt.Sprintf("%v[%v]", field, mapKey)
+		keyPtr := reflect.New(t.Key())
+		if !c.setValue(keyPtr, mapKey) {
+			c.fail(elemKey, elemField, t.Key(), c.source(elemKey))
+			continue
+		}
+		elemPtr := reflect.New(t.Elem())
+		if existing := mapValue.MapIndex(keyPtr.Elem()); existing.IsValid() {
+			elemPtr.Elem().Set(existing)
+		}
+		failed := len(c.failedFields)
+		c.populate(elemPtr, sf, elemKey, elemField)
+		if len(c.failedFields) > failed && !c.isNested(t.Elem()) {
+			continue // like slices, failed values are left out. Nested structs are always attempted in their entirety.
+		}
+		if mapValue.IsNil() {
+			mapValue.Set(reflect.MakeMap(t))
+		}
+		mapValue.SetMapIndex(keyPtr.Elem(), elemPtr.Elem())
+	}
+}
+
+// populateStructSlice populates the slice of structs ptr points to from indexed keys nested under key,
+// e.g. UPSTREAMS__0__HOST is upstreams[0].Host
+// Elements are ordered by index, and gaps between indices are skipped.
+// Indices must be written canonically, e.g. 1 rather than 01 or +1. Other keys are ignored.
+// As gaps are skipped, the index in a FieldError's Key and Field is the index in the source, not in the slice,
+// e.g. Upstreams[5] may be the second element.
+// The slice remains intact if no indexed keys are nested under key.
+func (c *Builder) populateStructSlice(ptr reflect.Value, sf reflect.StructField, key, field string) {
+	t := ptr.Elem().Type()
+	prefix := key + c.structDelim
+
+	var indices []int
+	seen := make(map[int]bool)
+	for k := range c.configMap {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		segment := strings.SplitN(strings.TrimPrefix(k, prefix), c.structDelim, 2)[0]
+		index, err := strconv.Atoi(segment)
+		if err == nil && index >= 0 && strconv.Itoa(index) == segment && !seen[index] { // e.g. not 01 or +1
+			seen[index] = true
+			indices = append(indices, index)
+		}
+	}
+	if indices == nil {
+		return
+	}
+	sort.Ints(indices)
+
+	sliceValue := reflect.MakeSlice(t, 0, len(indices))
+	for _, index := range indices {
+		elemPtr := reflect.New(t.Elem())
+		c.populate(elemPtr, sf, fmt.Sprintf("%v%v", prefix, index), fmt.Sprintf("%v[%v]", field, index))
+		sliceValue = reflect.Append(sliceValue, elemPtr.Elem())
+	}
+	ptr.Elem().Set(sliceValue)
+}
+
+// isMissing reports whether no value is present for the required field ptr points to, bound from key.
+// source is where the value of key came from, if anywhere.
+// Nested structs are never missing, as their fields are checked instead,
+// unless they are behind a nil pointer that no key is nested under.
+func (c *Builder) isMissing(ptr reflect.Value, key, source string) bool {
+	t := ptr.Elem().Type()
+	if !c.isNested(t) {
+		return !c.isPresent(key, t, source)
+	}
+	return t.Kind() == reflect.Ptr && ptr.Elem().IsNil() && source == "" && !c.hasPrefix(key+c.structDelim)
+}
+
+// isPresent reports whether a value of type t is present for key.
+// source is where the value of key came from, if anywhere.
+// Maps and slices of structs are present if any key is nested under key.
+func (c *Builder) isPresent(key string, t reflect.Type, source string) bool {
+	if source != "" {
+		return true
+	}
+	for t.Kind() == reflect.Ptr && !c.isScalar(t) {
+		t = t.Elem()
+	}
+	if c.isScalar(t) {
+		return false
+	}
+	dynamic := t.Kind() == reflect.Map || (t.Kind() == reflect.Slice && c.isNested(t.Elem()))
+	return dynamic && c.hasPrefix(key+c.structDelim)
+}
+
+// sliceDelimOf returns the delimiter for the slice values of sf.
+// The sep struct tag takes precedence over the Builder's delimiter.
+func (c *Builder) sliceDelimOf(sf reflect.StructField) string {
+	if sep := sf.Tag.Get(sepTagKey); sep != "" {
+		return sep
+	}
+	return c.sliceDelim
+}
+
+// split splits value, the value of key, into the elements of the slice or array sf.
+// Lists, e.g. JSON arrays, keep their own elements regardless of delimiters, unless interpolation changed them.
+func (c *Builder) split(key, value string, sf reflect.StructField) []string {
+	if list, ok := c.lists[key]; ok && value == c.configMap[key] {
+		return list
+	}
+	return stringToSlice(value, c.sliceDelimOf(sf))
+}
+
+// isNested reports whether t, or what t points to, is a struct that is populated field by field.
+func (c *Builder) isNested(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr && !c.isScalar(t) {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !c.isScalar(t)
+}
+
+// hasPrefix reports whether any key in the current config state starts with prefix.
+func (c *Builder) hasPrefix(prefix string) bool {
+	for k := range c.configMap {
+		if strings.HasPrefix(k, prefix) {
+			return true
 		}
 	}
+	return false
+}
+
+// lookup returns the value of key, with any variables expanded, and the name of the source it came from.
+// If no source provided key, but one provided key_FILE, the trimmed contents of the file it names are used instead.
+// Otherwise, the default struct tag of t is used, but only if current, the value of the field, is still its zero value,
+// and is set from a single value, see takesDefault.
+//
+// failure is non-nil if the value could not be looked up. Only its Kind and Err are set.
+func (c *Builder) lookup(key string, t reflect.StructField, current reflect.Value) (value, source string, failure *FieldError) {
+	if _, ok := c.configMap[key]; ok {
+		c.used[key] = true
+		value, err := c.expand(key, nil)
+		if err != nil {
+			return "", c.source(key), &FieldError{Kind: InterpolationFailure, Err: err}
+		}
+		return value, c.source(key), nil
+	}
+	if _, ok := c.configMap[key+fileSuffix]; ok {
+		c.used[key+fileSuffix] = true
+		source = c.source(key + fileSuffix)
+		file, err := c.expand(key+fileSuffix, nil)
+		if err != nil {
+			return "", source, &FieldError{Kind: InterpolationFailure, Err: err}
+		}
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", source, &FieldError{Kind: IOFailure, Err: err}
+		}
+		return strings.TrimSpace(string(content)), source, nil
+	}
+	if value, ok := t.Tag.Lookup(defaultTagKey); ok && c.takesDefault(current.Type()) && current.IsZero() {
+		c.defaulted[key] = true
+		return value, defaultSource, nil
+	}
+	return "", "", nil
+}
+
+// takesDefault reports whether a value of type t is set from a single value, and so can have a default.
+// Nested structs, maps and slices of structs are populated from the keys nested under them instead,
+// so the default struct tag has no effect on them, nor on their elements.
+func (c *Builder) takesDefault(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr && !c.isScalar(t) {
+		t = t.Elem()
+	}
+	switch c.kindOf(t) {
+	case reflect.Struct, reflect.Map:
+		return false
+	case reflect.Slice:
+		return !c.isNested(t.Elem())
+	}
+	return true
+}
+
+// fail records that a value from source could not be set on a field of type t.
+// The failure is reported under name, which is the field's key or an element of it.
+func (c *Builder) fail(name, field string, t reflect.Type, source string) {
+	kind := ParseFailure
+	if !supportedKind(t.Kind()) && !c.isScalar(t) {
+		kind = UnsupportedKind
+	}
+	c.failedFields = append(c.failedFields, &FieldError{
+		Key:    name,
+		Field:  field,
+		Type:   t,
+		Source: source,
+		Kind:   kind,
+	})
 }
 
 // getKey returns the string that represents this structField in the config map.
@@ -173,14 +915,35 @@ func (c *Builder) populateStructRecursively(structPtr reflect.Value, prefix stri
 // Otherwise, field's name is used.
 func getKey(t reflect.StructField, prefix string) string {
 	name := t.Name
-	if tag, exists := t.Tag.Lookup(structTagKey); exists {
-		if tag = strings.TrimSpace(tag); tag != "" {
-			name = tag
-		}
+	if tag, _ := parseTag(t); tag != "" {
+		name = tag
 	}
 	return strings.ToLower(prefix + name)
 }
 
+// tagOptions are the comma separated options that follow the name in a struct tag, e.g. config:"NAME,required"
+type tagOptions []string
+
+// parseTag splits the structTagKey struct tag of t into its name and options.
+// Surrounding whitespace is stripped from both.
+func parseTag(t reflect.StructField) (string, tagOptions) {
+	split := strings.Split(t.Tag.Get(structTagKey), ",")
+	for i := range split {
+		split[i] = strings.TrimSpace(split[i])
+	}
+	return split[0], tagOptions(split[1:])
+}
+
+// Contains reports whether opt is present in o.
+func (o tagOptions) Contains(opt string) bool {
+	for _, s := range o {
+		if s == opt {
+			return true
+		}
+	}
+	return false
+}
+
 // stringToSlice converts a string to a slice of string, using delim.
 // It strips surrounding whitespace of all entries.
 // If the input string is empty or all whitespace, nil is returned.
@@ -204,18 +967,18 @@ func stringToSlice(s, delim string) []string {
 }
 
 // convertAndSetSlice builds a slice of a dynamic type.
-// It converts each entry in "values" to the elemType of the passed in slice.
+// It converts each entry in "values" to the elemType of the passed in slice, using set.
 // The slice remains nil if "values" is empty.
 // All values are attempted.
 // Returns the indices of failed values
-func convertAndSetSlice(slicePtr reflect.Value, values []string) []int {
+func convertAndSetSlice(slicePtr reflect.Value, values []string, set func(settable reflect.Value, s string) bool) []int {
 	sliceVal := slicePtr.Elem()
 	elemType := sliceVal.Type().Elem()
 
 	var failedIndices []int
 	for i, s := range values {
 		valuePtr := reflect.New(elemType)
-		if !convertAndSetValue(valuePtr, s) {
+		if !set(valuePtr, s) {
 			failedIndices = append(failedIndices, i)
 		} else {
 			sliceVal.Set(reflect.Append(sliceVal, valuePtr.Elem()))
@@ -224,8 +987,96 @@ func convertAndSetSlice(slicePtr reflect.Value, values []string) []int {
 	return failedIndices
 }
 
+// convertAndSetArray sets each element of an array of a dynamic type.
+// It converts each entry in "values" to the elemType of the passed in array, using set.
+// "values" must be the same length as the array.
+// All values are attempted, and failed values are left intact.
+// Returns the indices of failed values
+func convertAndSetArray(arrayPtr reflect.Value, values []string, set func(settable reflect.Value, s string) bool) []int {
+	arrayVal := arrayPtr.Elem()
+
+	var failedIndices []int
+	for i, s := range values {
+		if !set(arrayVal.Index(i).Addr(), s) {
+			failedIndices = append(failedIndices, i)
+		}
+	}
+	return failedIndices
+}
+
+// supportedKind reports whether convertAndSetValue can handle values of kind k.
+func supportedKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// isScalar reports whether values of t are set directly from a single string,
+// either by a registered decoder or by the type itself.
+func (c *Builder) isScalar(t reflect.Type) bool {
+	_, ok := c.decoders[t]
+	return ok || isUnmarshaler(t)
+}
+
+// setValue is convertAndSetValue, except that a decoder registered for the settable's type takes precedence.
+func (c *Builder) setValue(settable reflect.Value, s string) bool {
+	decode, ok := c.decoders[settable.Elem().Type()]
+	if !ok || s == "" {
+		return convertAndSetValue(settable, s)
+	}
+	v, err := decode(s)
+	if err != nil || v == nil {
+		return false
+	}
+	decoded, settableValue := reflect.ValueOf(v), settable.Elem()
+	if !decoded.Type().AssignableTo(settableValue.Type()) {
+		if !decoded.Type().ConvertibleTo(settableValue.Type()) {
+			return false
+		}
+		decoded = decoded.Convert(settableValue.Type())
+	}
+	settableValue.Set(decoded)
+	return true
+}
+
+var (
+	textUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+	flagValueType         = reflect.TypeOf((*flag.Value)(nil)).Elem()
+)
+
+// isUnmarshaler reports whether a pointer to t can parse its own value from a string.
+// Such types are always set directly by convertAndSetValue, even if they are structs or slices.
+func isUnmarshaler(t reflect.Type) bool {
+	ptr := reflect.PtrTo(t)
+	return ptr.Implements(textUnmarshalerType) || ptr.Implements(binaryUnmarshalerType) || ptr.Implements(flagValueType)
+}
+
+// unmarshal sets the value of settable to s using the first of
+// encoding.TextUnmarshaler, encoding.BinaryUnmarshaler or flag.Value that it implements.
+// handled is false if settable implements none of them.
+func unmarshal(settable reflect.Value, s string) (handled bool, err error) {
+	switch u := settable.Interface().(type) {
+	case encoding.TextUnmarshaler:
+		return true, u.UnmarshalText([]byte(s))
+	case encoding.BinaryUnmarshaler:
+		return true, u.UnmarshalBinary([]byte(s))
+	case flag.Value:
+		return true, u.Set(s)
+	default:
+		return false, nil
+	}
+}
+
 // convertAndSetValue receives a settable of an arbitrary kind, and sets its value to s, returning true.
-// It calls the matching strconv function on s, based on the settable's kind.
+// Types that implement encoding.TextUnmarshaler, encoding.BinaryUnmarshaler or flag.Value parse s themselves.
+// Otherwise, it calls the matching strconv function on s, based on the settable's kind.
 // All basic types (bool, int, float, string) are handled by this function.
 // Slice and struct are handled elsewhere.
 //
@@ -236,6 +1087,9 @@ func convertAndSetValue(settable reflect.Value, s string) bool {
 	if s == "" {
 		return true
 	}
+	if handled, err := unmarshal(settable, s); handled {
+		return err == nil
+	}
 	settableValue := settable.Elem()
 	var (
 		err error
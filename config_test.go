@@ -1,9 +1,16 @@
 package config
 
 import (
+	"errors"
 	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -96,10 +103,70 @@ func Test_Integration(t *testing.T) {
 	if gotErr == nil {
 		t.Errorf("Integration: should have had an error")
 	}
-	if !reflect.DeepEqual(builder.failedFields, wantFailedFields) {
-		t.Errorf("Integration: gotFailedFields %+v, wantFailedFields %+v", builder.failedFields, wantFailedFields)
+	if !reflect.DeepEqual(failedKeys(builder.failedFields), wantFailedFields) {
+		t.Errorf("Integration: gotFailedFields %+v, wantFailedFields %+v", failedKeys(builder.failedFields), wantFailedFields)
+	}
+	var fieldErrs *FieldErrors
+	if !errors.As(gotErr, &fieldErrs) {
+		t.Fatalf("Integration: error should be a *FieldErrors, got %T", gotErr)
+	}
+	wantG := FieldError{Key: "g[1]", Field: "G[1]", Type: reflect.TypeOf(0), Source: "file[" + file.Name() + "]", Kind: ParseFailure}
+	if got := *fieldErrs.Errors[1]; !reflect.DeepEqual(got, wantG) {
+		t.Errorf("Integration: got FieldError %+v, want %+v", got, wantG)
+	}
+	if got := fieldErrs.Errors[0]; got.Kind != IOFailure || !os.IsNotExist(errors.Unwrap(got)) {
+		t.Errorf("Integration: got FieldError %+v, want a not exist IOFailure", got)
+	}
+	os.Clearenv()
+}
+
+func Test_FromEnvWithPrefix(t *testing.T) {
+	// cannot be Parallelized as it manipulates env vars.
+	// It must clear env afterwards to avoid bleeding env changes.
+	type testConfig struct {
+		Port  int
+		Name  string
+		Debug bool
 	}
 	os.Clearenv()
+	defer os.Clearenv()
+	os.Setenv("MYAPP_PORT", "1")
+	os.Setenv("myapp_name", "app") // the prefix matches in any case
+	os.Setenv("MyApp_Debug", "true")
+	os.Setenv("NAME", "unprefixed") // dropped
+	os.Setenv("MYAPP_PROT", "2")
+
+	want := testConfig{Port: 1, Name: "app", Debug: true}
+	for _, prefix := range []string{"MYAPP_", "myapp_"} {
+		var got testConfig
+		if err := FromEnvWithPrefix(prefix).To(&got); err != nil {
+			t.Errorf("FromEnvWithPrefix(%v): unexpected error %v", prefix, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("FromEnvWithPrefix(%v): got %+v, want %+v", prefix, got, want)
+		}
+	}
+
+	var got testConfig
+	builder := FromEnvWithPrefix("MYAPP_").Strict()
+	builder.To(&got)
+	if !reflect.DeepEqual(failedKeys(builder.failedFields), []string{"prot"}) || builder.failedFields[0].Kind != UnknownKey {
+		t.Errorf("FromEnvWithPrefix: Strict should report prefixed keys only, got %+v", failedKeys(builder.failedFields))
+	}
+
+	builder = FromEnv().Strict()
+	if err := builder.To(&got); err != nil {
+		t.Errorf("FromEnv: Strict should not check unprefixed env vars, got %v", err)
+	}
+}
+
+// failedKeys returns the Key of each FieldError.
+func failedKeys(fes []*FieldError) []string {
+	var keys []string
+	for _, fe := range fes {
+		keys = append(keys, fe.Key)
+	}
+	return keys
 }
 
 func Test_shouldPanic(t *testing.T) {
@@ -328,6 +395,39 @@ func Test_convertAndSetValue(t *testing.T) {
 			want:    func() interface{} { v := 0; return &v }(),
 			wantErr: true,
 		},
+		{
+			name: "encoding.TextUnmarshaler",
+			args: args{
+				settable: new(net.IP),
+				s:        "1.2.3.4",
+			},
+			want: func() interface{} { v := net.ParseIP("1.2.3.4"); return &v }(),
+		},
+		{
+			name: "encoding.TextUnmarshaler - bad convert",
+			args: args{
+				settable: new(net.IP),
+				s:        "abc",
+			},
+			want:    new(net.IP),
+			wantErr: true,
+		},
+		{
+			name: "encoding.BinaryUnmarshaler",
+			args: args{
+				settable: new(url.URL),
+				s:        "https://example.com/path",
+			},
+			want: &url.URL{Scheme: "https", Host: "example.com", Path: "/path"},
+		},
+		{
+			name: "flag.Value",
+			args: args{
+				settable: new(testFlagValue),
+				s:        "abc",
+			},
+			want: func() interface{} { v := testFlagValue("ABC"); return &v }(),
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
@@ -386,7 +486,7 @@ func Test_convertAndSetSlice(t *testing.T) {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			gotErr := convertAndSetSlice(reflect.ValueOf(tt.args.slicePtr), tt.args.values)
+			gotErr := convertAndSetSlice(reflect.ValueOf(tt.args.slicePtr), tt.args.values, convertAndSetValue)
 			if !reflect.DeepEqual(tt.args.slicePtr, tt.want) {
 				t.Errorf("convertAndSetSlice = %v, want: %v", tt.args.slicePtr, tt.want)
 			}
@@ -495,6 +595,28 @@ func Test_getKey(t *testing.T) {
 			},
 			want: "pre__tag",
 		},
+		{
+			name: "tag with options",
+			args: args{
+				t: reflect.StructField{
+					Name: "name",
+					Tag:  "config:\" tag , required\"",
+				},
+				prefix: "pre__",
+			},
+			want: "pre__tag",
+		},
+		{
+			name: "options only",
+			args: args{
+				t: reflect.StructField{
+					Name: "name",
+					Tag:  "config:\",required\"",
+				},
+				prefix: "pre__",
+			},
+			want: "pre__name",
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
@@ -506,3 +628,840 @@ func Test_getKey(t *testing.T) {
 		})
 	}
 }
+
+type testSource struct {
+	values map[string]string
+	err    error
+}
+
+func (s testSource) Values() (map[string]string, error) {
+	return s.values, s.err
+}
+
+func Test_FromSource(t *testing.T) {
+	t.Parallel()
+	type testConfig struct {
+		A int
+		B string
+		C string
+	}
+
+	got := testConfig{C: "hardcoded"}
+	want := testConfig{A: 2, B: "abc", C: "hardcoded"}
+	wantFailedFields := []string{"config.testSource"}
+
+	builder := FromSource(testSource{values: map[string]string{"A": "1", "b": "abc"}}).
+		FromSource(testSource{values: map[string]string{"a": "2", "C": ""}, err: os.ErrNotExist})
+	gotErr := builder.To(&got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromSource: got %+v, want %+v", got, want)
+	}
+	if gotErr == nil {
+		t.Errorf("FromSource: should have had an error")
+	}
+	if !reflect.DeepEqual(failedKeys(builder.failedFields), wantFailedFields) {
+		t.Errorf("FromSource: gotFailedFields %+v, wantFailedFields %+v", failedKeys(builder.failedFields), wantFailedFields)
+	}
+
+	builder.To(&got) // binding again reports the same failures, rather than accumulating them
+	if !reflect.DeepEqual(failedKeys(builder.failedFields), wantFailedFields) {
+		t.Errorf("FromSource: gotFailedFields %+v, wantFailedFields %+v", failedKeys(builder.failedFields), wantFailedFields)
+	}
+}
+
+func Test_FromJSON(t *testing.T) {
+	t.Parallel()
+	type sub struct {
+		Port  int
+		Hosts []string
+	}
+	type testConfig struct {
+		A     string
+		B     float64
+		C     bool
+		D     []int
+		E     string
+		F     []string  `sep:","`
+		G     [2]string `sep:";"`
+		H     []string  `sep:","`
+		Inner sub       `config:"inner"`
+		Subs  []sub
+	}
+
+	file, err := ioutil.TempFile("", "testjson")
+	if err != nil {
+		t.Fatalf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write([]byte(`{
+		"a": "abc",
+		"B": 1.5,
+		"c": true,
+		"d": [1, 2, 3],
+		"e": null,
+		"f": ["a b", "c"],
+		"g": ["a b", "c"],
+		"h": ["x"],
+		"inner": {"port": 8080, "hosts": ["x", "y"]},
+		"subs": [{"port": 1}, {"port": 2, "hosts": ["z"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("failed to write test data to temp file: %v", err)
+	}
+
+	var got testConfig
+	want := testConfig{
+		A:     "abc",
+		B:     1.5,
+		C:     true,
+		D:     []int{1, 2, 3},
+		F:     []string{"a b", "c"},
+		G:     [2]string{"a b", "c"},
+		H:     []string{"y", "z"},
+		Inner: sub{Port: 8080, Hosts: []string{"x", "y"}},
+		Subs:  []sub{{Port: 1}, {Port: 2, Hosts: []string{"z"}}},
+	}
+	wantFailedFields := []string{"file[nonexistfile]"}
+
+	builder := FromJSON(file.Name()).FromSource(testSource{values: map[string]string{"h": "y,z"}}).FromJSON("nonexistfile")
+	gotErr := builder.To(&got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromJSON: got %+v, want %+v", got, want)
+	}
+	if gotErr == nil {
+		t.Errorf("FromJSON: should have had an error")
+	}
+	if !reflect.DeepEqual(failedKeys(builder.failedFields), wantFailedFields) {
+		t.Errorf("FromJSON: gotFailedFields %+v, wantFailedFields %+v", failedKeys(builder.failedFields), wantFailedFields)
+	}
+
+	invalidTests := []struct {
+		content string
+		wantErr SyntaxError
+	}{
+		{content: "{\n\"password\": s3cr3t\n}", wantErr: SyntaxError{Line: 2, Msg: "invalid JSON"}},
+		{content: "{\"a\": \"abc\"}\n garbage", wantErr: SyntaxError{Line: 2, Msg: "unexpected data after JSON object"}},
+		{content: "{\"a\": \"abc\"} {}", wantErr: SyntaxError{Line: 1, Msg: "unexpected data after JSON object"}},
+		{content: "[1, 2]", wantErr: SyntaxError{Line: 1, Msg: "expected a JSON object"}},
+		{content: "{\"a\": \"abc\"", wantErr: SyntaxError{Line: 1, Msg: "unexpected end of JSON"}},
+		{content: "", wantErr: SyntaxError{Line: 1, Msg: "unexpected end of JSON"}},
+	}
+	for _, tt := range invalidTests {
+		invalid, err := ioutil.TempFile("", "testjson")
+		if err != nil {
+			t.Fatalf("failed to create temporary file: %v", err)
+		}
+		defer os.Remove(invalid.Name())
+		if _, err = invalid.Write([]byte(tt.content)); err != nil {
+			t.Fatalf("failed to write test data to temp file: %v", err)
+		}
+		var got testConfig
+		builder = FromJSON(invalid.Name())
+		_ = builder.To(&got)
+		tt.wantErr.File = invalid.Name()
+		if len(builder.failedFields) != 1 {
+			t.Fatalf("FromJSON(%q): got failedFields %+v, want one SyntaxFailure", tt.content, failedKeys(builder.failedFields))
+		}
+		if got := builder.failedFields[0]; got.Kind != SyntaxFailure || !reflect.DeepEqual(got.Err, &tt.wantErr) {
+			t.Errorf("FromJSON(%q): got FieldError %+v, want a SyntaxFailure of %v", tt.content, got, &tt.wantErr)
+		}
+		if !reflect.DeepEqual(got, testConfig{}) {
+			t.Errorf("FromJSON(%q): nothing should be bound, got %+v", tt.content, got)
+		}
+	}
+}
+
+func Test_defaults(t *testing.T) {
+	t.Parallel()
+	type sub struct {
+		Hosts []string `default:"a b"`
+	}
+	type testConfig struct {
+		A   int               `default:"1"`
+		B   string            `default:"default"`
+		C   time.Duration     `default:"1m"`
+		D   int               `default:"x"` // should log D as the default is an incorrect type
+		E   string            `default:""`  // no effect
+		F   int               `default:"1"` // pre-populated values take precedence over defaults
+		G   []int             `default:"2 3"`
+		P   *int              `default:"3"`
+		L   map[string]string `default:"x"` // no effect on maps, nested structs, or slices of structs
+		S   sub               `default:"x"`
+		Ss  []sub             `default:"x"`
+		Sub sub
+	}
+
+	got := testConfig{F: 2, G: []int{1}}
+	three := 3
+	want := testConfig{
+		A:   1,
+		B:   "overridden",
+		C:   time.Minute,
+		F:   2,
+		G:   []int{1},
+		P:   &three,
+		S:   sub{Hosts: []string{"a", "b"}},
+		Ss:  []sub{{Hosts: []string{"c"}}},
+		Sub: sub{Hosts: []string{"a", "b"}},
+	}
+	wantFailedFields := []string{"d"}
+	wantDefaulted := []string{"a", "c", "d", "e", "p", "s__hosts", "sub__hosts"}
+
+	builder := FromSource(testSource{values: map[string]string{"B": "overridden", "SS__0__HOSTS": "c"}})
+	gotErr := builder.To(&got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("defaults: got %+v, want %+v", got, want)
+	}
+	if gotErr == nil {
+		t.Errorf("defaults: should have had an error")
+	}
+	if !reflect.DeepEqual(failedKeys(builder.failedFields), wantFailedFields) {
+		t.Errorf("defaults: gotFailedFields %+v, wantFailedFields %+v", failedKeys(builder.failedFields), wantFailedFields)
+	}
+	if got := builder.failedFields[0].Source; got != defaultSource {
+		t.Errorf("defaults: got Source %v, want %v", got, defaultSource)
+	}
+	var gotDefaulted []string
+	for key := range builder.defaulted {
+		gotDefaulted = append(gotDefaulted, key)
+	}
+	sort.Strings(gotDefaulted)
+	if !reflect.DeepEqual(gotDefaulted, wantDefaulted) {
+		t.Errorf("defaults: got defaulted %+v, want %+v", gotDefaulted, wantDefaulted)
+	}
+}
+
+func Test_required(t *testing.T) {
+	t.Parallel()
+	type sub struct {
+		Host  string   `config:",required"`
+		Hosts []string `config:"hosts,required"`
+	}
+	type testConfig struct {
+		A   string `config:"A,required"`
+		B   int    `config:",required" default:"1"` // defaults satisfy required
+		C   string `config:",required"`
+		D   string
+		Sub sub  `config:"sub,required"` // no effect on structs
+		TLS *sub `config:",required"`    // unless behind a pointer with no keys nested under it
+		Ptr *sub `config:",required"`
+	}
+
+	var got testConfig
+	want := testConfig{A: "abc", B: 1, Ptr: &sub{Host: "x", Hosts: []string{"y"}}}
+	wantFailedFields := []string{"c", "sub__host", "sub__hosts", "tls"}
+
+	builder := FromSource(testSource{values: map[string]string{"A": "abc", "PTR__HOST": "x", "PTR__HOSTS": "y"}})
+	gotErr := builder.To(&got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("required: got %+v, want %+v", got, want)
+	}
+	if gotErr == nil {
+		t.Errorf("required: should have had an error")
+	}
+	if !reflect.DeepEqual(failedKeys(builder.failedFields), wantFailedFields) {
+		t.Errorf("required: gotFailedFields %+v, wantFailedFields %+v", failedKeys(builder.failedFields), wantFailedFields)
+	}
+	for _, fe := range builder.failedFields {
+		if fe.Kind != MissingValue {
+			t.Errorf("required: got Kind %v for %v, want %v", fe.Kind, fe.Key, MissingValue)
+		}
+	}
+}
+
+// testFlagValue implements flag.Value, upper casing its value.
+type testFlagValue string
+
+func (f *testFlagValue) String() string { return string(*f) }
+
+func (f *testFlagValue) Set(s string) error {
+	*f = testFlagValue(strings.ToUpper(s))
+	return nil
+}
+
+func Test_unmarshalers(t *testing.T) {
+	t.Parallel()
+	type sub struct {
+		Started time.Time
+	}
+	type testConfig struct {
+		IP   net.IP
+		IPs  []net.IP
+		URL  url.URL
+		Big  big.Int
+		Sub  sub
+		Bad  net.IP
+		Flag testFlagValue `config:",required"`
+	}
+
+	var got testConfig
+	want := testConfig{
+		IP:   net.ParseIP("1.2.3.4"),
+		IPs:  []net.IP{net.ParseIP("::1"), net.ParseIP("5.6.7.8")},
+		URL:  url.URL{Scheme: "https", Host: "example.com"},
+		Sub:  sub{Started: time.Date(2020, 8, 17, 0, 0, 0, 0, time.UTC)},
+		Flag: "ABC",
+	}
+	want.Big.SetString("123456789012345678901234567890", 10)
+	wantFailedFields := []string{"ips[1]", "bad"}
+
+	builder := FromSource(testSource{values: map[string]string{
+		"IP":           "1.2.3.4",
+		"IPS":          "::1 x 5.6.7.8",
+		"URL":          "https://example.com",
+		"BIG":          "123456789012345678901234567890",
+		"SUB__STARTED": "2020-08-17T00:00:00Z",
+		"BAD":          "x",
+		"FLAG":         "abc",
+	}})
+	gotErr := builder.To(&got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unmarshalers: got %+v, want %+v", got, want)
+	}
+	if gotErr == nil {
+		t.Errorf("unmarshalers: should have had an error")
+	}
+	if !reflect.DeepEqual(failedKeys(builder.failedFields), wantFailedFields) {
+		t.Errorf("unmarshalers: gotFailedFields %+v, wantFailedFields %+v", failedKeys(builder.failedFields), wantFailedFields)
+	}
+	for _, fe := range builder.failedFields {
+		if fe.Kind != ParseFailure {
+			t.Errorf("unmarshalers: got Kind %v for %v, want %v", fe.Kind, fe.Key, ParseFailure)
+		}
+	}
+}
+
+func Test_WithDecoder(t *testing.T) {
+	t.Parallel()
+	type celsius float64
+	type testConfig struct {
+		Date  time.Time
+		Dates []time.Time
+		Temp  celsius
+		Bad   celsius
+		Wrong int
+	}
+
+	parseDate := func(s string) (interface{}, error) {
+		return time.Parse("2006-01-02", s)
+	}
+	parseCelsius := func(s string) (interface{}, error) {
+		f, err := strconv.ParseFloat(strings.TrimSuffix(s, "C"), 64)
+		return f, err // float64 is convertible to celsius
+	}
+	wrongType := func(s string) (interface{}, error) {
+		return s, nil // string is not assignable to int
+	}
+
+	var got testConfig
+	want := testConfig{
+		Date:  time.Date(2020, 8, 17, 0, 0, 0, 0, time.UTC),
+		Dates: []time.Time{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		Temp:  21.5,
+	}
+	wantFailedFields := []string{"dates[1]", "bad", "wrong"}
+
+	builder := FromSource(testSource{values: map[string]string{
+		"DATE":  "2020-08-17",
+		"DATES": "2020-01-01 2020-01-01T00:00:00Z",
+		"TEMP":  "21.5C",
+		"BAD":   "hot",
+		"WRONG": "1",
+	}}).
+		WithDecoder(reflect.TypeOf(time.Time{}), parseDate).
+		WithDecoder(reflect.TypeOf(celsius(0)), parseCelsius).
+		WithDecoder(reflect.TypeOf(0), wrongType)
+	gotErr := builder.To(&got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WithDecoder: got %+v, want %+v", got, want)
+	}
+	if gotErr == nil {
+		t.Errorf("WithDecoder: should have had an error")
+	}
+	if !reflect.DeepEqual(failedKeys(builder.failedFields), wantFailedFields) {
+		t.Errorf("WithDecoder: gotFailedFields %+v, wantFailedFields %+v", failedKeys(builder.failedFields), wantFailedFields)
+	}
+}
+
+func Test_pointers(t *testing.T) {
+	t.Parallel()
+	type tls struct {
+		Cert string
+		Key  string `default:"key.pem"`
+	}
+	type testConfig struct {
+		Timeout  *int
+		Retries  *int
+		Default  *int `default:"3"`
+		Existing *int
+		Hosts    *[]string
+		URL      *url.URL
+		TLS      *tls
+		Unset    *tls
+		Bad      *int
+		Required *int `config:",required"`
+	}
+
+	existing := 1
+	got := testConfig{Existing: &existing}
+	want := testConfig{
+		Timeout:  func() *int { v := 0; return &v }(), // present, so set even though zero
+		Default:  func() *int { v := 3; return &v }(),
+		Existing: func() *int { v := 2; return &v }(),
+		Hosts:    &[]string{"a", "b"},
+		URL:      &url.URL{Scheme: "https", Host: "example.com"},
+		TLS:      &tls{Cert: "cert.pem", Key: "key.pem"},
+		Bad:      new(int),
+	}
+	wantFailedFields := []string{"bad", "required"}
+
+	builder := FromSource(testSource{values: map[string]string{
+		"TIMEOUT":   "0",
+		"EXISTING":  "2",
+		"HOSTS":     "a b",
+		"URL":       "https://example.com",
+		"TLS__CERT": "cert.pem",
+		"BAD":       "x",
+	}})
+	gotErr := builder.To(&got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pointers: got %+v, want %+v", got, want)
+	}
+	if got.Existing != &existing {
+		t.Errorf("pointers: existing pointers should be populated in place")
+	}
+	if gotErr == nil {
+		t.Errorf("pointers: should have had an error")
+	}
+	if !reflect.DeepEqual(failedKeys(builder.failedFields), wantFailedFields) {
+		t.Errorf("pointers: gotFailedFields %+v, wantFailedFields %+v", failedKeys(builder.failedFields), wantFailedFields)
+	}
+}
+
+func Test_maps(t *testing.T) {
+	t.Parallel()
+	type tenant struct {
+		Port  int
+		Hosts []string
+	}
+	type testConfig struct {
+		Labels   map[string]string
+		Weights  map[string]float64
+		Ports    map[int]int
+		Tenants  map[string]tenant
+		Regions  map[string]*tenant
+		Existing map[string]string
+		Unset    map[string]string
+		Required map[string]string `config:",required"`
+		Missing  map[string]string `config:",required"`
+	}
+
+	got := testConfig{Existing: map[string]string{"a": "1", "b": "2"}}
+	want := testConfig{
+		Labels:   map[string]string{"team": "core", "tier": "1", "nested__key": "x"},
+		Weights:  map[string]float64{"a": 0.5},
+		Ports:    map[int]int{80: 8080},
+		Tenants:  map[string]tenant{"acme": {Port: 1, Hosts: []string{"a", "b"}}, "globex": {Port: 2}},
+		Regions:  map[string]*tenant{"us": {Port: 3}},
+		Existing: map[string]string{"a": "1", "b": "3"},
+		Required: map[string]string{"a": "b"},
+	}
+	wantFailedFields := []string{"weights__b", "ports__x", "missing"}
+
+	builder := FromSource(testSource{values: map[string]string{
+		"LABELS__TEAM":          "core",
+		"LABELS__TIER":          "1",
+		"LABELS__NESTED__KEY":   "x",
+		"WEIGHTS__A":            "0.5",
+		"WEIGHTS__B":            "x",
+		"PORTS__80":             "8080",
+		"PORTS__X":              "1",
+		"TENANTS__ACME__PORT":   "1",
+		"TENANTS__ACME__HOSTS":  "a b",
+		"TENANTS__GLOBEX__PORT": "2",
+		"REGIONS__US__PORT":     "3",
+		"EXISTING__B":           "3",
+		"REQUIRED__A":           "b",
+	}})
+	gotErr := builder.To(&got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("maps: got %+v, want %+v", got, want)
+	}
+	if gotErr == nil {
+		t.Errorf("maps: should have had an error")
+	}
+	if !reflect.DeepEqual(failedKeys(builder.failedFields), wantFailedFields) {
+		t.Errorf("maps: gotFailedFields %+v, wantFailedFields %+v", failedKeys(builder.failedFields), wantFailedFields)
+	}
+}
+
+func Test_structSlices(t *testing.T) {
+	t.Parallel()
+	type upstream struct {
+		Host string
+		Port int `default:"80"`
+	}
+	type testConfig struct {
+		Upstreams []upstream
+		Replicas  []*upstream
+		Existing  []upstream
+		Unset     []upstream
+		Required  []upstream `config:",required"`
+	}
+
+	got := testConfig{
+		Existing: []upstream{{Host: "replaced"}},
+		Unset:    []upstream{{Host: "intact"}},
+	}
+	want := testConfig{
+		Upstreams: []upstream{{Host: "a", Port: 1}, {Host: "b", Port: 80}, {Host: "c", Port: 0}},
+		Replicas:  []*upstream{{Host: "d", Port: 80}},
+		Existing:  []upstream{{Host: "e", Port: 80}},
+		Unset:     []upstream{{Host: "intact"}},
+	}
+	wantFailedFields := []string{"upstreams__10__port", "required"}
+
+	builder := FromSource(testSource{values: map[string]string{
+		"UPSTREAMS__0__HOST":  "a",
+		"UPSTREAMS__0__PORT":  "1",
+		"UPSTREAMS__2__HOST":  "b", // gaps are skipped
+		"UPSTREAMS__10__HOST": "c", // ordered numerically
+		"UPSTREAMS__10__PORT": "x",
+		"UPSTREAMS__X__HOST":  "ignored",
+		"UPSTREAMS__01__HOST": "ignored", // indices must be canonical
+		"UPSTREAMS__+2__HOST": "ignored",
+		"REPLICAS__0__HOST":   "d",
+		"EXISTING__0__HOST":   "e",
+	}})
+	gotErr := builder.To(&got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("structSlices: got %+v, want %+v", got, want)
+	}
+	if gotErr == nil {
+		t.Errorf("structSlices: should have had an error")
+	}
+	if !reflect.DeepEqual(failedKeys(builder.failedFields), wantFailedFields) {
+		t.Errorf("structSlices: gotFailedFields %+v, wantFailedFields %+v", failedKeys(builder.failedFields), wantFailedFields)
+	}
+}
+
+func Test_convertAndSetArray(t *testing.T) {
+	t.Parallel()
+	type args struct {
+		arrayPtr interface{}
+		values   []string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    interface{}
+		wantErr []int
+	}{
+		{
+			name: "float array",
+			args: args{
+				arrayPtr: new([3]float64),
+				values:   []string{"0.1", "0.2", "0.7"},
+			},
+			want: &[3]float64{0.1, 0.2, 0.7},
+		},
+		{
+			name: "byte array - bad values",
+			args: args{
+				arrayPtr: &[4]byte{9, 9, 9, 9},
+				values:   []string{"1", "x", "256", "4"},
+			},
+			want:    &[4]byte{1, 9, 9, 4},
+			wantErr: []int{1, 2},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			gotErr := convertAndSetArray(reflect.ValueOf(tt.args.arrayPtr), tt.args.values, convertAndSetValue)
+			if !reflect.DeepEqual(tt.args.arrayPtr, tt.want) {
+				t.Errorf("convertAndSetArray = %v, want: %v", tt.args.arrayPtr, tt.want)
+			}
+			if !reflect.DeepEqual(gotErr, tt.wantErr) {
+				t.Errorf("convertAndSetArray err = %v, want: %v", gotErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_arrays(t *testing.T) {
+	t.Parallel()
+	type testConfig struct {
+		Weights [3]float64
+		Key     [4]byte
+		Short   [3]int
+		Unset   [2]int
+	}
+
+	got := testConfig{Short: [3]int{1, 2, 3}, Unset: [2]int{1, 2}}
+	want := testConfig{
+		Weights: [3]float64{0.1, 0, 0.7},
+		Key:     [4]byte{1, 2, 3, 4},
+		Short:   [3]int{1, 2, 3}, // left intact on a length mismatch
+		Unset:   [2]int{1, 2},
+	}
+	wantFailedFields := []string{"weights[1]", "short"}
+
+	builder := FromSource(testSource{values: map[string]string{
+		"WEIGHTS": "0.1 x 0.7",
+		"KEY":     "1 2 3 4",
+		"SHORT":   "4 5",
+	}})
+	gotErr := builder.To(&got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("arrays: got %+v, want %+v", got, want)
+	}
+	if gotErr == nil {
+		t.Errorf("arrays: should have had an error")
+	}
+	if !reflect.DeepEqual(failedKeys(builder.failedFields), wantFailedFields) {
+		t.Errorf("arrays: gotFailedFields %+v, wantFailedFields %+v", failedKeys(builder.failedFields), wantFailedFields)
+	}
+}
+
+func Test_delimiters(t *testing.T) {
+	t.Parallel()
+	type sub struct {
+		Hosts []string
+		Ports []int `sep:";"`
+	}
+	type testConfig struct {
+		A   []string
+		B   [2]int `sep:"|"`
+		Sub sub
+	}
+
+	var got testConfig
+	want := testConfig{
+		A:   []string{"a b", "c"},
+		B:   [2]int{1, 2},
+		Sub: sub{Hosts: []string{"x", "y"}, Ports: []int{1, 2}},
+	}
+
+	builder := WithStructDelim(".").WithSliceDelim(",").FromSource(testSource{values: map[string]string{
+		"A":         "a b, c",
+		"B":         "1|2",
+		"SUB.HOSTS": "x,y",
+		"SUB.PORTS": "1;2",
+	}})
+	if err := builder.To(&got); err != nil {
+		t.Errorf("delimiters: unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("delimiters: got %+v, want %+v", got, want)
+	}
+}
+
+func Test_emptyDelimiterShouldPanic(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{name: "struct", fn: func() { WithStructDelim("") }},
+		{name: "slice", fn: func() { WithSliceDelim("") }},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("should have caused a panic")
+				}
+			}()
+			tt.fn()
+		})
+	}
+}
+
+func Test_FromDotenv(t *testing.T) {
+	t.Parallel()
+	type testConfig struct {
+		A string
+		B string
+		C string
+		D string
+	}
+
+	file, err := ioutil.TempFile("", "testdotenv")
+	if err != nil {
+		t.Fatalf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	testData := strings.Join([]string{
+		"# comment",
+		"export A=1",
+		`B="multi`,
+		`line"`,
+		"C='quoted # not a comment' # comment",
+		"not an entry",
+		"D=never parsed",
+	}, "\n")
+	_, err = file.Write([]byte(testData))
+	if err != nil {
+		t.Fatalf("failed to write test data to temp file: %v", err)
+	}
+
+	var got testConfig
+	want := testConfig{A: "1", B: "multi\nline", C: "quoted # not a comment"}
+	wantErr := &SyntaxError{File: file.Name(), Line: 6, Msg: "expected KEY=VALUE"}
+
+	builder := From(file.Name())
+	gotErr := builder.To(&got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromDotenv: got %+v, want %+v", got, want)
+	}
+	if gotErr == nil {
+		t.Fatalf("FromDotenv: should have had an error")
+	}
+	if got := builder.failedFields[0]; got.Kind != SyntaxFailure || !reflect.DeepEqual(got.Err, wantErr) {
+		t.Errorf("FromDotenv: got FieldError %+v, want a SyntaxFailure of %v", got, wantErr)
+	}
+}
+
+func Test_fileIndirection(t *testing.T) {
+	t.Parallel()
+	type sub struct {
+		Token *string
+	}
+	type testConfig struct {
+		Password string `config:"DATABASE_PASSWORD,required"`
+		Port     int    `default:"1"`
+		Direct   string
+		Missing  string
+		Sub      sub
+		Paths    map[string]string
+		Tenants  map[string]sub
+	}
+
+	file, err := ioutil.TempFile("", "testsecret")
+	if err != nil {
+		t.Fatalf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	if _, err = file.Write([]byte("  s3cr3t\n")); err != nil {
+		t.Fatalf("failed to write test data to temp file: %v", err)
+	}
+	portFile, err := ioutil.TempFile("", "testport")
+	if err != nil {
+		t.Fatalf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(portFile.Name())
+	if _, err = portFile.Write([]byte("8080\n")); err != nil {
+		t.Fatalf("failed to write test data to temp file: %v", err)
+	}
+
+	var got testConfig
+	want := testConfig{
+		Password: "s3cr3t",
+		Port:     8080, // the file takes precedence over defaults
+		Direct:   "direct",
+		Sub:      sub{Token: func() *string { v := "s3cr3t"; return &v }()},
+		Paths:    map[string]string{"log_file": "/var/log/app.log"},                              // map keys are literal
+		Tenants:  map[string]sub{"acme": {Token: func() *string { v := "s3cr3t"; return &v }()}}, // unlike fields of nested structs
+	}
+	wantFailedFields := []string{"missing"}
+
+	builder := FromSource(testSource{values: map[string]string{
+		"DATABASE_PASSWORD_FILE":    file.Name(),
+		"PORT_FILE":                 portFile.Name(),
+		"DIRECT":                    "direct",
+		"DIRECT_FILE":               file.Name(),
+		"MISSING_FILE":              "nonexistfile",
+		"SUB__TOKEN_FILE":           file.Name(),
+		"PATHS__LOG_FILE":           "/var/log/app.log",
+		"TENANTS__ACME__TOKEN_FILE": file.Name(),
+	}})
+	gotErr := builder.To(&got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fileIndirection: got %+v, want %+v", got, want)
+	}
+	if gotErr == nil {
+		t.Fatalf("fileIndirection: should have had an error")
+	}
+	if !reflect.DeepEqual(failedKeys(builder.failedFields), wantFailedFields) {
+		t.Errorf("fileIndirection: gotFailedFields %+v, wantFailedFields %+v", failedKeys(builder.failedFields), wantFailedFields)
+	}
+	if got := builder.failedFields[0]; got.Kind != IOFailure || !os.IsNotExist(errors.Unwrap(got)) {
+		t.Errorf("fileIndirection: got FieldError %+v, want a not exist IOFailure", got)
+	}
+}
+
+func Test_FromDir(t *testing.T) {
+	t.Parallel()
+	type tls struct {
+		Cert string
+	}
+	type db struct {
+		Host string
+	}
+	type testConfig struct {
+		DatabaseURL string `config:"DATABASE_URL"`
+		Port        int
+		TLS         tls
+		Primary     db
+		Replica     db
+	}
+
+	dir, err := ioutil.TempDir("", "testdir")
+	if err != nil {
+		t.Fatalf("failed to create temporary dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// mimics the layout of a mounted Kubernetes volume
+	mustWrite := func(path, content string) {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write test data: %v", err)
+		}
+	}
+	mustSymlink := func(oldname, newname string) {
+		if err := os.Symlink(oldname, newname); err != nil {
+			t.Fatalf("failed to symlink: %v", err)
+		}
+	}
+	mustWrite(filepath.Join(dir, "..2020_08_17", "DATABASE_URL"), "db://\n")
+	mustWrite(filepath.Join(dir, "..2020_08_17", "tls", "cert"), "-----BEGIN CERTIFICATE-----\n...\n")
+	mustSymlink("..2020_08_17", filepath.Join(dir, "..data"))
+	mustSymlink(filepath.Join("..data", "DATABASE_URL"), filepath.Join(dir, "DATABASE_URL"))
+	mustSymlink(filepath.Join("..data", "tls"), filepath.Join(dir, "tls"))
+	mustSymlink(".", filepath.Join(dir, "loop")) // should not recurse forever
+	mustWrite(filepath.Join(dir, "port"), " 8080 \n")
+	mustWrite(filepath.Join(dir, "..shared", "host"), "h")
+	mustSymlink("..shared", filepath.Join(dir, "primary")) // siblings are not loops, so both are read
+	mustSymlink("..shared", filepath.Join(dir, "replica"))
+
+	var got testConfig
+	want := testConfig{
+		DatabaseURL: "db://",
+		Port:        8080,
+		TLS:         tls{Cert: "-----BEGIN CERTIFICATE-----\n..."},
+		Primary:     db{Host: "h"},
+		Replica:     db{Host: "h"},
+	}
+	wantFailedFields := []string{"dir[nonexistdir]"}
+
+	builder := FromDir(dir).FromDir("nonexistdir")
+	gotErr := builder.To(&got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromDir: got %+v, want %+v", got, want)
+	}
+	if gotErr == nil {
+		t.Errorf("FromDir: should have had an error")
+	}
+	if !reflect.DeepEqual(failedKeys(builder.failedFields), wantFailedFields) {
+		t.Errorf("FromDir: gotFailedFields %+v, wantFailedFields %+v", failedKeys(builder.failedFields), wantFailedFields)
+	}
+}
@@ -0,0 +1,238 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SyntaxError is reported when a source is not in a valid format.
+// It never holds the offending value, to prevent accidental logging of secrets.
+type SyntaxError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%v:%v: %v", e.File, e.Line, e.Msg)
+}
+
+// parseDotenv parses content in the dotenv format used by docker-compose and direnv.
+//
+//	# comments, and blank lines are ignored
+//	export KEY=value # the export keyword and trailing comments are optional
+//	KEY='single quoted values are literal, and may span
+//	multiple lines'
+//	KEY="double quoted values may span multiple lines, and expand \n \r \t \" \\ and \$"
+//
+// Surrounding whitespace is stripped from keys and unquoted values.
+//
+// The line each key was defined on is returned alongside the values.
+//
+// file is only used for error reporting.
+// Parsing stops at the first syntax error, returning the values parsed so far.
+func parseDotenv(file, content string) (map[string]string, map[string]int, error) {
+	p := &dotenvParser{file: file, src: content, line: 1}
+	values, lines := make(map[string]string), make(map[string]int)
+	for {
+		p.skipBlankLinesAndComments()
+		if p.eof() {
+			return values, lines, nil
+		}
+		line := p.line
+		key, value, err := p.parseEntry()
+		if err != nil {
+			return values, lines, err
+		}
+		values[key], lines[key] = value, line
+	}
+}
+
+// dotenvParser holds the state of parseDotenv.
+// pos is the byte offset of the next unread character in src, which is on line.
+type dotenvParser struct {
+	file, src string
+	pos, line int
+}
+
+func (p *dotenvParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *dotenvParser) peek() byte {
+	return p.src[p.pos]
+}
+
+// next consumes and returns the next character, keeping track of line numbers.
+func (p *dotenvParser) next() byte {
+	b := p.src[p.pos]
+	p.pos++
+	if b == '\n' {
+		p.line++
+	}
+	return b
+}
+
+func (p *dotenvParser) errorf(line int, format string, args ...interface{}) error {
+	return &SyntaxError{File: p.file, Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// skipSpaces consumes spaces and tabs, but not newlines.
+func (p *dotenvParser) skipSpaces() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t' || p.peek() == '\r') {
+		p.next()
+	}
+}
+
+// skipLine consumes the remainder of the current line, including its newline.
+func (p *dotenvParser) skipLine() {
+	for !p.eof() && p.next() != '\n' {
+	}
+}
+
+func (p *dotenvParser) skipBlankLinesAndComments() {
+	for {
+		p.skipSpaces()
+		if p.eof() {
+			return
+		}
+		switch p.peek() {
+		case '\n':
+			p.next()
+		case '#':
+			p.skipLine()
+		default:
+			return
+		}
+	}
+}
+
+// parseEntry parses a single KEY=VALUE entry, including any trailing comment.
+func (p *dotenvParser) parseEntry() (key, value string, err error) {
+	line := p.line
+	start := p.pos
+	for !p.eof() && p.peek() != '=' && p.peek() != '\n' {
+		p.next()
+	}
+	key = strings.TrimSpace(p.src[start:p.pos])
+	if fields := strings.Fields(key); len(fields) == 2 && fields[0] == "export" {
+		key = fields[1]
+	}
+	if p.eof() || p.peek() != '=' {
+		return "", "", p.errorf(line, "expected KEY=VALUE")
+	}
+	if !validDotenvKey(key) {
+		return "", "", p.errorf(line, "invalid key")
+	}
+	p.next() // =
+	afterEquals := p.pos
+	p.skipSpaces()
+
+	if p.eof() {
+		return key, "", nil
+	}
+	if p.peek() == '#' && p.pos > afterEquals {
+		p.skipLine() // a comment, as the # is preceded by whitespace
+		return key, "", nil
+	}
+	switch p.peek() {
+	case '\'':
+		value, err = p.parseSingleQuoted()
+	case '"':
+		value, err = p.parseDoubleQuoted()
+	default:
+		return key, p.parseUnquoted(), nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	p.skipSpaces()
+	if p.eof() {
+		return key, value, nil
+	}
+	switch p.peek() {
+	case '\n':
+		p.next()
+	case '#':
+		p.skipLine()
+	default:
+		return "", "", p.errorf(p.line, "unexpected character after quoted value")
+	}
+	return key, value, nil
+}
+
+// parseUnquoted parses the remainder of the line, stripping any trailing comment.
+// A comment starts at a # preceded by whitespace.
+func (p *dotenvParser) parseUnquoted() string {
+	start := p.pos
+	p.skipLine()
+	value := strings.TrimSuffix(p.src[start:p.pos], "\n")
+	for i := 1; i < len(value); i++ {
+		if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+			value = value[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(value)
+}
+
+func (p *dotenvParser) parseSingleQuoted() (string, error) {
+	line := p.line
+	p.next() // '
+	start := p.pos
+	for !p.eof() {
+		if p.next() == '\'' {
+			return p.src[start : p.pos-1], nil
+		}
+	}
+	return "", p.errorf(line, "unterminated quoted value")
+}
+
+func (p *dotenvParser) parseDoubleQuoted() (string, error) {
+	line := p.line
+	p.next() // "
+	var b strings.Builder
+	for !p.eof() {
+		switch c := p.next(); c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			if p.eof() {
+				break
+			}
+			switch e := p.next(); e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(e)
+			default:
+				b.WriteByte(c)
+				b.WriteByte(e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf(line, "unterminated quoted value")
+}
+
+// validDotenvKey reports whether key is made up of only letters, digits, underscores, dots and dashes,
+// and does not start with a digit.
+func validDotenvKey(key string) bool {
+	if key == "" || (key[0] >= '0' && key[0] <= '9') {
+		return false
+	}
+	for _, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseDotenv(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		in      string
+		want    map[string]string
+		wantErr *SyntaxError
+	}{
+		{
+			name: "empty",
+			in:   "",
+			want: map[string]string{},
+		},
+		{
+			name: "unquoted",
+			in:   "A=1\nB = two words \r\nC=\nD=a=b\n",
+			want: map[string]string{"A": "1", "B": "two words", "C": "", "D": "a=b"},
+		},
+		{
+			name: "comments and blank lines",
+			in:   "# comment\n\n   \n  # indented comment\nA=1 # trailing comment\nB=a#b\nC=#\nD= # comment\n",
+			want: map[string]string{"A": "1", "B": "a#b", "C": "#", "D": ""},
+		},
+		{
+			name: "export",
+			in:   "export A=1\nexport\tB=2\nexport=3",
+			want: map[string]string{"A": "1", "B": "2", "export": "3"},
+		},
+		{
+			name: "single quoted",
+			in:   "A='  with spaces # and \\n not a comment '\nB='multi\nline' # comment\nC=''",
+			want: map[string]string{"A": "  with spaces # and \\n not a comment ", "B": "multi\nline", "C": ""},
+		},
+		{
+			name: "double quoted",
+			in:   `A="tab\tnewline\n\"quote\" \\ \$HOME \x"` + "\nB=\"multi\nline\"\nC=\"'\"",
+			want: map[string]string{"A": "tab\tnewline\n\"quote\" \\ $HOME \\x", "B": "multi\nline", "C": "'"},
+		},
+		{
+			name:    "missing equals",
+			in:      "A=1\n\nB\nC=3",
+			want:    map[string]string{"A": "1"},
+			wantErr: &SyntaxError{File: "test.env", Line: 3, Msg: "expected KEY=VALUE"},
+		},
+		{
+			name:    "invalid key",
+			in:      "A=1\nB C=2",
+			want:    map[string]string{"A": "1"},
+			wantErr: &SyntaxError{File: "test.env", Line: 2, Msg: "invalid key"},
+		},
+		{
+			name:    "unterminated quote",
+			in:      "A='1\n\nB=2",
+			want:    map[string]string{},
+			wantErr: &SyntaxError{File: "test.env", Line: 1, Msg: "unterminated quoted value"},
+		},
+		{
+			name:    "unterminated escaped quote",
+			in:      `A="1\"`,
+			want:    map[string]string{},
+			wantErr: &SyntaxError{File: "test.env", Line: 1, Msg: "unterminated quoted value"},
+		},
+		{
+			name:    "trailing characters",
+			in:      "A=\"1\"\nB='multi\nline'2",
+			want:    map[string]string{"A": "1"},
+			wantErr: &SyntaxError{File: "test.env", Line: 3, Msg: "unexpected character after quoted value"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, _, gotErr := parseDotenv("test.env", tt.in)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDotenv() = %q, want %q", got, tt.want)
+			}
+			if tt.wantErr == nil && gotErr != nil || tt.wantErr != nil && !reflect.DeepEqual(gotErr, tt.wantErr) {
+				t.Errorf("parseDotenv() err = %v, want %v", gotErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_parseDotenvLines(t *testing.T) {
+	t.Parallel()
+	in := "# comment\nA=1\n\nexport B='multi\nline'\nC=3\nA=4"
+	want := map[string]int{"A": 7, "B": 4, "C": 6}
+	if _, got, _ := parseDotenv("test.env", in); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDotenv() lines = %v, want %v", got, want)
+	}
+}
+
+func TestSyntaxError_Error(t *testing.T) {
+	t.Parallel()
+	err := &SyntaxError{File: "dev.config", Line: 3, Msg: "invalid key"}
+	want := "dev.config:3: invalid key"
+	if got := err.Error(); got != want {
+		t.Errorf("SyntaxError.Error() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	secretTagKey = "secret"
+	secretOption = "secret"
+	redacted     = "******"
+)
+
+// Dump renders target, a struct or struct pointer, as KEY=value lines. See Builder.Dump.
+func Dump(target interface{}) string {
+	return newBuilder().Dump(target)
+}
+
+// Dump renders target, a struct or struct pointer, as KEY=value lines, e.g. for startup logs.
+// Keys are exactly the keys that To binds, and nested values are rendered the same way they are set.
+// Unset pointers, and every value nested under them, are rendered as empty values, so every key that can be set is listed.
+// Unexported fields are skipped, as they are by To.
+//
+// The values of fields tagged as secret are redacted, as are all fields nested under them.
+//   Password string `config:"DATABASE_PASSWORD,secret"`
+//   Token    string `secret:"true"`
+// It panics if target is not a struct or struct pointer.
+func (c *Builder) Dump(target interface{}) string {
+	v := reflect.ValueOf(target)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		panic("config: Dump(target) must be a struct or *struct")
+	}
+	if !v.CanAddr() {
+		ptr := reflect.New(v.Type()) // so that methods on pointers, e.g. MarshalText, are found
+		ptr.Elem().Set(v)
+		v = ptr.Elem()
+	}
+	var b strings.Builder
+	c.dumpStructRecursively(&b, v, "", false, false)
+	return b.String()
+}
+
+// dumpStructRecursively renders each field of the struct v, prefixing their keys with prefix.
+// If unset, v is the zero value of a nil pointer, and every value is rendered as empty.
+func (c *Builder) dumpStructRecursively(b *strings.Builder, v reflect.Value, prefix string, secret, unset bool) {
+	walkStruct(v.Type(), prefix, "", func(i int, sf reflect.StructField, key, _ string) {
+		c.dump(b, v.Field(i), sf, key, secret || isSecret(sf), unset)
+	})
+}
+
+// dump renders v, which is sf or what sf points to, as key.
+func (c *Builder) dump(b *strings.Builder, v reflect.Value, sf reflect.StructField, key string, secret, unset bool) {
+	t := v.Type()
+	switch kind := c.kindOf(t); kind {
+	case reflect.Struct:
+		c.dumpStructRecursively(b, v, key+c.structDelim, secret, unset)
+	case reflect.Ptr:
+		if v.IsNil() {
+			v, unset = reflect.New(t.Elem()), true
+		}
+		c.dump(b, v.Elem(), sf, key, secret, unset)
+	case reflect.Slice, reflect.Array:
+		if kind == reflect.Slice && c.isNested(t.Elem()) {
+			for i := 0; i < v.Len(); i++ {
+				c.dump(b, v.Index(i), sf, fmt.Sprintf("%v%v%v", key, c.structDelim, i), secret, unset)
+			}
+			return
+		}
+		if unset {
+			writeLine(b, key, "")
+			return
+		}
+		values := make([]string, v.Len())
+		for i := range values {
+			values[i] = formatValue(v.Index(i))
+		}
+		writeLine(b, key, redact(strings.Join(values, c.sliceDelimOf(sf)), secret))
+	case reflect.Map:
+		mapKeys := v.MapKeys()
+		formatted := make(map[string]reflect.Value, len(mapKeys))
+		names := make([]string, len(mapKeys))
+		for i, mapKey := range mapKeys {
+			names[i] = formatValue(mapKey)
+			formatted[names[i]] = v.MapIndex(mapKey)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			c.dump(b, formatted[name], sf, key+c.structDelim+strings.ToLower(name), secret, unset)
+		}
+	default:
+		if unset {
+			writeLine(b, key, "")
+			return
+		}
+		writeLine(b, key, redact(formatValue(v), secret))
+	}
+}
+
+// isSecret reports whether sf is tagged as secret, either with config:",secret" or with the secret struct tag.
+func isSecret(sf reflect.StructField) bool {
+	if _, opts := parseTag(sf); opts.Contains(secretOption) {
+		return true
+	}
+	tag, ok := sf.Tag.Lookup(secretTagKey)
+	if !ok {
+		return false
+	}
+	secret, err := strconv.ParseBool(tag)
+	return err != nil || secret // anything but an explicit false is secret, to err on the side of caution
+}
+
+// redact replaces s if secret, unless it is empty.
+func redact(s string, secret bool) string {
+	if secret && s != "" {
+		return redacted
+	}
+	return s
+}
+
+// formatValue renders v the same way it would be set, preferring encoding.TextMarshaler and fmt.Stringer.
+func formatValue(v reflect.Value) string {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return ""
+	}
+	i := v.Interface()
+	if v.CanAddr() {
+		i = v.Addr().Interface() // includes methods on the pointer
+	}
+	switch i := i.(type) {
+	case encoding.TextMarshaler:
+		if text, err := i.MarshalText(); err == nil {
+			return string(text)
+		}
+	case fmt.Stringer:
+		return i.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// writeLine writes key=value to b. Keys are upper cased, as is conventional for environment variables.
+// Values that span multiple lines are quoted.
+func writeLine(b *strings.Builder, key, value string) {
+	if strings.ContainsAny(value, "\r\n") {
+		value = strconv.Quote(value)
+	}
+	fmt.Fprintf(b, "%v=%v\n", strings.ToUpper(key), value)
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrorKind classifies why a FieldError occurred.
+type ErrorKind int
+
+const (
+	// ParseFailure means a value could not be converted to the field's type.
+	ParseFailure ErrorKind = iota + 1
+	// UnsupportedKind means a value was provided for a field whose type cannot be bound.
+	UnsupportedKind
+	// IOFailure means a source could not be read.
+	IOFailure
+	// MissingValue means no source provided a value for a field tagged as required.
+	MissingValue
+	// SyntaxFailure means a source was not in a valid format. Err is a *SyntaxError.
+	SyntaxFailure
+	// InterpolationFailure means the variables in a value could not be expanded, e.g. due to a cycle.
+	InterpolationFailure
+	// UnknownKey means a source provided a key that did not match any field. Only reported by Strict.
+	// Err suggests the closest known keys, if any.
+	UnknownKey
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ParseFailure:
+		return "failed to parse"
+	case UnsupportedKind:
+		return "unsupported type"
+	case IOFailure:
+		return "failed to read"
+	case MissingValue:
+		return "missing required"
+	case SyntaxFailure:
+		return "invalid syntax"
+	case InterpolationFailure:
+		return "failed to interpolate"
+	case UnknownKey:
+		return "unknown key"
+	default:
+		return fmt.Sprintf("ErrorKind(%d)", int(k))
+	}
+}
+
+// FieldError describes a single failure encountered while building config.
+// It never holds the offending value, to prevent accidental logging of secrets.
+type FieldError struct {
+	// Key is the config key, e.g. subconfig__port. Slice elements include their index, e.g. ipwhitelist[1].
+	// For source failures it is the name of the source, e.g. file[dev.config].
+	Key string
+	// Field is the path to the struct field, e.g. SubConfig.Port. Empty for source failures.
+	Field string
+	// Type is the type the value was converted to. Nil for source failures.
+	Type reflect.Type
+	// Source names the source that supplied the value, e.g. env or file[dev.config].
+	Source string
+	Kind   ErrorKind
+	// Err is the underlying error, if any. It is only set when it cannot contain a value, e.g. file i/o.
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "config: %v: %v", e.Key, e.Kind)
+	if e.Type != nil {
+		fmt.Fprintf(&b, " %v", e.Type)
+	}
+	if e.Source != "" && e.Source != e.Key {
+		fmt.Fprintf(&b, " from %v", e.Source)
+	}
+	if e.Err != nil {
+		fmt.Fprintf(&b, ": %v", e.Err)
+	}
+	return b.String()
+}
+
+// Unwrap returns the underlying error, if any.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// FieldErrors is returned by To when any field or source failed.
+// The entirety of the struct is always attempted, so it holds one entry per failure.
+type FieldErrors struct {
+	Errors []*FieldError
+}
+
+func (e *FieldErrors) Error() string {
+	keys := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		keys[i] = fe.Key
+	}
+	return fmt.Sprintf("config: the following fields had errors: %v", keys)
+}
+
+// Unwrap returns each FieldError, so that errors.Is and errors.As match individual failures, as of Go 1.20.
+func (e *FieldErrors) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, fe := range e.Errors {
+		errs[i] = fe
+	}
+	return errs
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestFieldError_Error(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		err  *FieldError
+		want string
+	}{
+		{
+			name: "parse failure",
+			err:  &FieldError{Key: "sub__port", Field: "Sub.Port", Type: reflect.TypeOf(0), Source: "env", Kind: ParseFailure},
+			want: "config: sub__port: failed to parse int from env",
+		},
+		{
+			name: "unsupported kind",
+			err:  &FieldError{Key: "c", Field: "C", Type: reflect.TypeOf(make(chan int)), Source: "file[dev.config]", Kind: UnsupportedKind},
+			want: "config: c: unsupported type chan int from file[dev.config]",
+		},
+		{
+			name: "io failure",
+			err:  &FieldError{Key: "file[dev.config]", Source: "file[dev.config]", Kind: IOFailure, Err: errors.New("boom")},
+			want: "config: file[dev.config]: failed to read: boom",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("FieldError.Error() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldErrors_Error(t *testing.T) {
+	t.Parallel()
+	err := &FieldErrors{Errors: []*FieldError{{Key: "file[x]"}, {Key: "g[1]"}, {Key: "h"}}}
+	want := "config: the following fields had errors: [file[x] g[1] h]"
+	if got := err.Error(); got != want {
+		t.Errorf("FieldErrors.Error() = %v, want %v", got, want)
+	}
+}
+
+func TestFieldErrors_Unwrap(t *testing.T) {
+	t.Parallel()
+	type testConfig struct {
+		Port     int
+		Password string
+	}
+
+	var c testConfig
+	err := FromSource(testSource{values: map[string]string{"PORT": "x", "PASSWORD_FILE": "nonexistfile"}}).To(&c)
+
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Key != "port" || fe.Kind != ParseFailure {
+		t.Errorf("errors.As: got %+v, want the FieldError of port", fe)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("errors.Is: should match the not exist error of password, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"flag"
+	"reflect"
+)
+
+// flagSourceName is the name of the source that explicitly set flags are merged as.
+const flagSourceName = "flags"
+
+// FromFlags returns a new Builder, populated with the flags explicitly set by args.
+func FromFlags(fs *flag.FlagSet, args []string) *Builder {
+	return newBuilder().FromFlags(fs, args)
+}
+
+// FromFlags defines a flag on fs for each field of the target passed to To, parses args with fs,
+// and merges every flag that was explicitly set. Flags take precedence over all other sources, regardless of order.
+// As flags can only be defined once the target is known, this all happens when To is called.
+//
+// Flags are named after the same keys that To binds, with nested structs separated by a dot regardless of the struct delimiter,
+// e.g. -port or --subconfig.ipwhitelist. Their values are bound the same way as the values of any other source.
+// Maps and slices of structs have no flags, as their keys are not known ahead of time.
+//
+// Flags already defined on fs are not defined again, but are still merged if their name matches a key.
+// If fs has already been parsed, args are ignored. Otherwise errors parsing args are reported by To,
+// unless the error handling of fs says otherwise, e.g. flag.ExitOnError.
+func (c *Builder) FromFlags(fs *flag.FlagSet, args []string) *Builder {
+	c.flagSet, c.flagArgs, c.flagsMerged = fs, args, false
+	return c
+}
+
+// mergeFlags defines a flag for each key of t, a struct type, then parses and merges them as the last source.
+func (c *Builder) mergeFlags(t reflect.Type) {
+	c.flagsMerged = true
+	keys := make(map[string]string) // flag names to the keys they set
+	c.defineFlagsRecursively(keys, t, "", "", "", make(map[reflect.Type]bool))
+	if !c.flagSet.Parsed() {
+		if err := c.flagSet.Parse(c.flagArgs); err != nil {
+			c.sourceFailures = append(c.sourceFailures, &FieldError{Key: flagSourceName, Source: flagSourceName, Kind: SyntaxFailure, Err: err})
+		}
+	}
+	c.FromSource(flagSource{fs: c.flagSet, keys: keys})
+}
+
+// defineFlagsRecursively defines a flag for each field of the struct type t, prefixing their keys with prefix,
+// and their names with namePrefix.
+// visiting holds the struct types currently being defined, so that recursive types terminate.
+func (c *Builder) defineFlagsRecursively(keys map[string]string, t reflect.Type, prefix, namePrefix, path string, visiting map[reflect.Type]bool) {
+	if visiting[t] {
+		return
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+	walkStruct(t, prefix, path, func(_ int, sf reflect.StructField, key, field string) {
+		c.defineFlag(keys, sf, sf.Type, key, namePrefix+getKey(sf, ""), field, visiting)
+	})
+}
+
+// defineFlag defines the flag name for key, whose type t is sf or what sf points to.
+func (c *Builder) defineFlag(keys map[string]string, sf reflect.StructField, t reflect.Type, key, name, field string, visiting map[reflect.Type]bool) {
+	switch kind := c.kindOf(t); kind {
+	case reflect.Struct:
+		c.defineFlagsRecursively(keys, t, key+c.structDelim, name+".", field+".", visiting)
+	case reflect.Ptr:
+		c.defineFlag(keys, sf, t.Elem(), key, name, field, visiting)
+	case reflect.Map:
+	case reflect.Slice:
+		if !c.isNested(t.Elem()) {
+			c.defineLeafFlag(keys, sf, key, name, field, false)
+		}
+	default:
+		c.defineLeafFlag(keys, sf, key, name, field, kind == reflect.Bool)
+	}
+}
+
+// defineLeafFlag defines the flag name for key, unless fs already has a flag of the same name.
+// Its default is the default struct tag of sf, so that it is shown by fs.PrintDefaults.
+func (c *Builder) defineLeafFlag(keys map[string]string, sf reflect.StructField, key, name, field string, isBool bool) {
+	keys[name] = key
+	if c.flagSet.Lookup(name) == nil {
+		c.flagSet.Var(&flagValue{value: sf.Tag.Get(defaultTagKey), isBool: isBool}, name, "sets "+field)
+	}
+}
+
+// flagValue is a flag.Value that holds the raw value of a flag, which is converted when bound.
+type flagValue struct {
+	value  string
+	isBool bool
+}
+
+func (f *flagValue) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.value
+}
+
+func (f *flagValue) Set(s string) error {
+	f.value = s
+	return nil
+}
+
+// IsBoolFlag allows bool fields to be set without a value, e.g. -debug
+func (f *flagValue) IsBoolFlag() bool {
+	return f.isBool
+}
+
+// flagSource reads the flags of fs that were explicitly set, and are named in keys.
+type flagSource struct {
+	fs   *flag.FlagSet
+	keys map[string]string
+}
+
+func (f flagSource) Values() (map[string]string, error) {
+	m := make(map[string]string)
+	f.fs.Visit(func(fl *flag.Flag) {
+		if key, ok := f.keys[fl.Name]; ok {
+			m[key] = fl.Value.String()
+		}
+	})
+	return m, nil
+}
+
+func (f flagSource) String() string {
+	return flagSourceName
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestBuilder_FromFlags(t *testing.T) {
+	t.Parallel()
+	type subConfig struct {
+		IPWhitelist []string
+	}
+	type testConfig struct {
+		Port      int  `default:"8080"`
+		Debug     bool `config:"DEBUG_MODE"`
+		Name      string
+		Timeout   *int
+		SubConfig subConfig
+		Labels    map[string]string
+		Upstreams []subConfig
+	}
+
+	newFlagSet := func() *flag.FlagSet {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		return fs
+	}
+
+	t.Run("defines flags", func(t *testing.T) {
+		t.Parallel()
+		fs := newFlagSet()
+		var c testConfig
+		if err := FromFlags(fs, nil).To(&c); err != nil {
+			t.Fatalf("FromFlags: unexpected error %v", err)
+		}
+		var got []string
+		fs.VisitAll(func(f *flag.Flag) { got = append(got, f.Name) })
+		sort.Strings(got)
+		want := []string{"debug_mode", "name", "port", "subconfig.ipwhitelist", "timeout"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("FromFlags: got %+v, want %+v", got, want)
+		}
+		if got := fs.Lookup("port").DefValue; got != "8080" {
+			t.Errorf("FromFlags: default got %+v, want %+v", got, "8080")
+		}
+	})
+
+	t.Run("explicitly set flags take precedence", func(t *testing.T) {
+		t.Parallel()
+		fs := newFlagSet()
+		var c testConfig
+		b := FromFlags(fs, []string{"--port", "9000", "-debug_mode", "-subconfig.ipwhitelist=0.0.0.0 127.0.0.1"}).
+			FromSource(testSource{values: map[string]string{"port": "1", "name": "app", "timeout": "5"}})
+		if err := b.To(&c); err != nil {
+			t.Fatalf("FromFlags: unexpected error %v", err)
+		}
+		timeout := 5
+		want := testConfig{
+			Port:      9000,
+			Debug:     true,
+			Name:      "app",
+			Timeout:   &timeout,
+			SubConfig: subConfig{IPWhitelist: []string{"0.0.0.0", "127.0.0.1"}},
+		}
+		if !reflect.DeepEqual(c, want) {
+			t.Errorf("FromFlags: got %+v, want %+v", c, want)
+		}
+		if got := b.origins["port"]; got[len(got)-1].Source != flagSourceName {
+			t.Errorf("FromFlags: origin got %+v, want %+v", got, flagSourceName)
+		}
+	})
+
+	t.Run("existing flags are not redefined", func(t *testing.T) {
+		t.Parallel()
+		fs := newFlagSet()
+		port := fs.Int("port", 0, "")
+		verbose := fs.Bool("v", false, "")
+		var c testConfig
+		if err := FromFlags(fs, []string{"-v", "-port=9000"}).To(&c); err != nil {
+			t.Fatalf("FromFlags: unexpected error %v", err)
+		}
+		if !*verbose || *port != 9000 {
+			t.Errorf("FromFlags: existing flags should be parsed, got %+v, %+v", *verbose, *port)
+		}
+		if c.Port != 9000 {
+			t.Errorf("FromFlags: got %+v, want %+v", c.Port, 9000)
+		}
+	})
+
+	t.Run("names are independent of the struct delimiter", func(t *testing.T) {
+		t.Parallel()
+		type sub struct {
+			Host string `config:"DB_HOST"`
+		}
+		type delimConfig struct {
+			DatabaseURL string `config:"DATABASE_URL"`
+			Sub         sub
+		}
+		var c delimConfig
+		err := WithStructDelim("_").FromFlags(newFlagSet(), []string{"--database_url", "x", "--sub.db_host", "y"}).To(&c)
+		if err != nil {
+			t.Fatalf("FromFlags: unexpected error %v", err)
+		}
+		if want := (delimConfig{DatabaseURL: "x", Sub: sub{Host: "y"}}); c != want {
+			t.Errorf("FromFlags: got %+v, want %+v", c, want)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+		var c testConfig
+		err := FromFlags(newFlagSet(), []string{"--unknown", "--port=x"}).To(&c)
+		var fieldErrs *FieldErrors
+		if !errors.As(err, &fieldErrs) || len(fieldErrs.Errors) != 1 || fieldErrs.Errors[0].Kind != SyntaxFailure {
+			t.Fatalf("FromFlags: expected a syntax failure, got %v", err)
+		}
+
+		err = FromFlags(newFlagSet(), []string{"--port=x"}).To(&c)
+		if !errors.As(err, &fieldErrs) || len(fieldErrs.Errors) != 1 || fieldErrs.Errors[0].Source != flagSourceName {
+			t.Fatalf("FromFlags: expected a parse failure from flags, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package config
+
+import "sync/atomic"
+
+// Holder owns a config struct of type T, and can be read from any number of goroutines while it is reloaded.
+// Its value only ever comes from a successful binding, so readers never observe a partially bound or invalid config.
+// Use NewHolder to create one. The zero value holds the zero value of T until Reload succeeds.
+type Holder[T any] struct {
+	v atomic.Value // holds *T, which is never modified once stored
+}
+
+// NewHolder binds the Builder to a new T, and returns a Holder of the result.
+// If binding fails, or T is not a struct, the error is returned instead.
+func NewHolder[T any](b *Builder) (*Holder[T], error) {
+	h := &Holder[T]{}
+	if err := h.Reload(b); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Load returns a snapshot of the current config. It never blocks, and is safe to call while Reload runs.
+// Slices, maps and pointers in the snapshot are shared with every other snapshot of the same config, and must not be modified.
+// If nothing has been bound yet, the zero value of T is returned.
+func (h *Holder[T]) Load() T {
+	current, ok := h.v.Load().(*T)
+	if !ok {
+		var zero T
+		return zero
+	}
+	return *current
+}
+
+// Reload reads the sources of the Builder again, binds them to a new T, starting from its zero value,
+// and replaces the config of the Holder with it. As the sources are read again, the same Builder can be reused.
+// If binding fails, the config is left unchanged and the error is returned.
+func (h *Holder[T]) Reload(b *Builder) error {
+	next, err := Load[T](b.rebuild())
+	if err != nil {
+		return err
+	}
+	h.v.Store(&next)
+	return nil
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestHolder(t *testing.T) {
+	t.Parallel()
+	type testConfig struct {
+		Port  int
+		Debug bool `default:"true"`
+	}
+
+	var zero Holder[testConfig]
+	if got := zero.Load(); got != (testConfig{}) {
+		t.Errorf("Load: zero Holder should hold the zero value, got %+v", got)
+	}
+	if err := zero.Reload(FromSource(testSource{values: map[string]string{"port": "3"}})); err != nil {
+		t.Errorf("Reload: unexpected error %v", err)
+	}
+	if got, want := zero.Load(), (testConfig{Port: 3, Debug: true}); got != want {
+		t.Errorf("Load: got %+v, want %+v", got, want)
+	}
+
+	if h, err := NewHolder[testConfig](FromSource(testSource{values: map[string]string{"port": "x"}})); err == nil || h != nil {
+		t.Fatalf("NewHolder: initial binding should have failed, got %+v", h)
+	}
+
+	h, err := NewHolder[testConfig](FromSource(testSource{values: map[string]string{"port": "1"}}))
+	if err != nil {
+		t.Fatalf("NewHolder: unexpected error %v", err)
+	}
+	if got, want := h.Load(), (testConfig{Port: 1, Debug: true}); got != want {
+		t.Errorf("Load: got %+v, want %+v", got, want)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if c := h.Load(); c.Port != 1 && c.Port != 2 {
+					t.Errorf("Load: unexpected snapshot %+v", c)
+				}
+			}
+		}()
+	}
+	if err := h.Reload(FromSource(testSource{values: map[string]string{"port": "2"}})); err != nil {
+		t.Errorf("Reload: unexpected error %v", err)
+	}
+	wg.Wait()
+
+	if err := h.Reload(FromSource(testSource{values: map[string]string{"port": "x"}})); err == nil {
+		t.Errorf("Reload: expected error, got none")
+	}
+	if got, want := h.Load(), (testConfig{Port: 2, Debug: true}); got != want {
+		t.Errorf("Load: failed reloads should not be stored, got %+v, want %+v", got, want)
+	}
+}
+
+func TestHolder_ReloadReusedBuilder(t *testing.T) {
+	t.Parallel()
+	type testConfig struct {
+		Port int
+	}
+
+	file, err := ioutil.TempFile("", "testholder")
+	if err != nil {
+		t.Fatalf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	write := func(content string) {
+		if err := ioutil.WriteFile(file.Name(), []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write test data to temp file: %v", err)
+		}
+	}
+
+	write("PORT=1")
+	builder := From(file.Name())
+	h, err := NewHolder[testConfig](builder)
+	if err != nil {
+		t.Fatalf("NewHolder: unexpected error %v", err)
+	}
+
+	write("PORT=2")
+	if err := h.Reload(builder); err != nil {
+		t.Fatalf("Reload: unexpected error %v", err)
+	}
+	if got, want := h.Load(), (testConfig{Port: 2}); got != want {
+		t.Errorf("Reload: sources should have been read again, got %+v, want %+v", got, want)
+	}
+
+	write("PORT=x")
+	for i := 0; i < 2; i++ {
+		err := h.Reload(builder)
+		var fieldErrs *FieldErrors
+		if !errors.As(err, &fieldErrs) || !reflect.DeepEqual(failedKeys(fieldErrs.Errors), []string{"port"}) {
+			t.Errorf("Reload: failures should not accumulate, got %v", err)
+		}
+	}
+	if got, want := h.Load(), (testConfig{Port: 2}); got != want {
+		t.Errorf("Load: failed reloads should not be stored, got %+v, want %+v", got, want)
+	}
+}
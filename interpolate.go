@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// WithInterpolation returns a new Builder that expands variables in values.
+func WithInterpolation(fromEnv bool) *Builder {
+	return newBuilder().WithInterpolation(fromEnv)
+}
+
+// WithInterpolation enables the expansion of ${VAR} and ${VAR:-default} in values, returning the Builder.
+// Variables are expanded when To is called, so they resolve against the merged config state of all sources.
+// If fromEnv is true, variables that are not in the config state fall back to the environment of the process.
+// Otherwise, and for values that are not set anywhere, the default is used, which is empty if omitted.
+// $${ is left as a literal ${
+//
+// Cycles, e.g. A=${B} and B=${A}, are reported as an InterpolationFailure.
+func (c *Builder) WithInterpolation(fromEnv bool) *Builder {
+	c.interpolate, c.interpolateEnv = true, fromEnv
+	return c
+}
+
+// expand returns the value of key, with any variables expanded.
+// visiting holds the keys currently being expanded, to detect cycles.
+func (c *Builder) expand(key string, visiting []string) (string, error) {
+	value := c.configMap[key]
+	if !c.interpolate || !strings.Contains(value, "${") {
+		return value, nil
+	}
+	for i, k := range visiting {
+		if k == key {
+			return "", fmt.Errorf("cycle %v -> %v", strings.Join(visiting[i:], " -> "), key)
+		}
+	}
+	visiting = append(visiting, key)
+
+	var b strings.Builder
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			break
+		}
+		if start > 0 && value[start-1] == '$' {
+			b.WriteString(value[:start] + "{") // value[:start] ends with the escaped $
+			value = value[start+2:]
+			continue
+		}
+		end := strings.Index(value[start:], "}")
+		if end < 0 {
+			break // unterminated, so it is not a variable
+		}
+		b.WriteString(value[:start])
+		name, def := value[start+2:start+end], ""
+		if i := strings.Index(name, ":-"); i >= 0 {
+			name, def = name[:i], name[i+2:]
+		}
+		expanded, err := c.resolve(strings.TrimSpace(name), def, visiting)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(expanded)
+		value = value[start+end+1:]
+	}
+	b.WriteString(value)
+	return b.String(), nil
+}
+
+// resolve returns the expanded value of the variable name, or def if it is not set.
+func (c *Builder) resolve(name, def string, visiting []string) (string, error) {
+	if key := strings.ToLower(name); c.configMap[key] != "" {
+		c.used[key] = true
+		return c.expand(key, visiting)
+	}
+	if c.interpolateEnv {
+		if value := os.Getenv(name); value != "" {
+			return value, nil
+		}
+	}
+	return def, nil
+}
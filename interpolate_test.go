@@ -0,0 +1,126 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestBuilder_expand(t *testing.T) {
+	t.Parallel()
+	configMap := map[string]string{
+		"db_host": "localhost",
+		"db_port": "5432",
+		"url":     "postgres://${DB_HOST}:${DB_PORT}/app",
+		"nested":  "${URL}?sslmode=${SSLMODE:-disable}",
+		"a":       "${B}",
+		"b":       "${C}",
+		"c":       "${A}",
+		"self":    "${SELF}",
+		"cyclic":  "x${A}",
+	}
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{name: "no variables", value: "plain $value {}", want: "plain $value {}"},
+		{name: "variables", value: "${DB_HOST}:${db_port}", want: "localhost:5432"},
+		{name: "whitespace", value: "${ DB_HOST }", want: "localhost"},
+		{name: "recursive", value: "${NESTED}", want: "postgres://localhost:5432/app?sslmode=disable"},
+		{name: "unset", value: "[${UNSET}]", want: "[]"},
+		{name: "default", value: "${UNSET:-fallback}", want: "fallback"},
+		{name: "default when set", value: "${DB_HOST:-fallback}", want: "localhost"},
+		{name: "escaped", value: "$${DB_HOST} ${DB_HOST}", want: "${DB_HOST} localhost"},
+		{name: "unterminated", value: "${DB_HOST", want: "${DB_HOST"},
+		{name: "cycle", value: "${A}", wantErr: "cycle a -> b -> c -> a"},
+		{name: "self cycle", value: "${SELF}", wantErr: "cycle self -> self"},
+		{name: "cycle through value", value: "${CYCLIC}", wantErr: "cycle a -> b -> c -> a"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := newBuilder().WithInterpolation(false)
+			for k, v := range configMap {
+				c.configMap[k] = v
+			}
+			c.configMap["test"] = tt.value
+			got, gotErr := c.expand("test", nil)
+			if got != tt.want {
+				t.Errorf("expand() = %v, want %v", got, tt.want)
+			}
+			if (gotErr == nil) != (tt.wantErr == "") || gotErr != nil && gotErr.Error() != tt.wantErr {
+				t.Errorf("expand() err = %v, want %v", gotErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_interpolation(t *testing.T) {
+	// cannot be Parallelized as it manipulates env vars.
+	type testConfig struct {
+		URL     string
+		Home    string
+		Cycle   string
+		Literal string
+	}
+
+	os.Clearenv()
+	defer os.Clearenv()
+	if err := os.Setenv("DB_HOST", "env-host"); err != nil {
+		t.Fatalf("failed to set environ: %v", err)
+	}
+	if err := os.Setenv("HOME", "/home/test"); err != nil {
+		t.Fatalf("failed to set environ: %v", err)
+	}
+
+	values := map[string]string{
+		"DB_PORT": "5432",
+		"URL":     "postgres://${DB_HOST}:${DB_PORT}/app",
+		"HOME":    "${HOME}", // a cycle, as the config state shadows the environment
+		"CYCLE":   "${CYCLE}",
+		"LITERAL": "${DB_PORT}",
+	}
+
+	tests := []struct {
+		name             string
+		builder          *Builder
+		want             testConfig
+		wantFailedFields []string
+	}{
+		{
+			name:    "disabled",
+			builder: FromSource(testSource{values: values}),
+			want:    testConfig{URL: "postgres://${DB_HOST}:${DB_PORT}/app", Home: "${HOME}", Cycle: "${CYCLE}", Literal: "${DB_PORT}"},
+		},
+		{
+			name:             "config state only",
+			builder:          FromSource(testSource{values: values}).WithInterpolation(false),
+			want:             testConfig{URL: "postgres://:5432/app", Literal: "5432"},
+			wantFailedFields: []string{"home", "cycle"},
+		},
+		{
+			name:             "fallback to env",
+			builder:          FromSource(testSource{values: values}).WithInterpolation(true),
+			want:             testConfig{URL: "postgres://env-host:5432/app", Literal: "5432"},
+			wantFailedFields: []string{"home", "cycle"},
+		},
+	}
+	for _, tt := range tests {
+		var got testConfig
+		_ = tt.builder.To(&got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: got %+v, want %+v", tt.name, got, tt.want)
+		}
+		if !reflect.DeepEqual(failedKeys(tt.builder.failedFields), tt.wantFailedFields) {
+			t.Errorf("%v: gotFailedFields %+v, wantFailedFields %+v", tt.name, failedKeys(tt.builder.failedFields), tt.wantFailedFields)
+		}
+		for _, fe := range tt.builder.failedFields {
+			if fe.Kind != InterpolationFailure {
+				t.Errorf("%v: got Kind %v for %v, want %v", tt.name, fe.Kind, fe.Key, InterpolationFailure)
+			}
+		}
+	}
+}
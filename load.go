@@ -0,0 +1,22 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Load binds the Builder to a new T, and returns the result.
+// It is equivalent to declaring a T and calling To with a pointer to it, e.g.
+//
+//	c, err := config.Load[MyConfig](config.From("dev.config").FromEnv())
+//
+// As with To, every field is attempted even if some fail, so the result is returned alongside any error.
+// Unlike To, it returns an error rather than panicking if T is not a struct.
+func Load[T any](b *Builder) (T, error) {
+	var target T
+	if t := reflect.TypeOf(&target).Elem(); t.Kind() != reflect.Struct {
+		return target, fmt.Errorf("config: Load[%v] requires a struct type", t)
+	}
+	err := b.To(&target)
+	return target, err
+}
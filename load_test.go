@@ -0,0 +1,38 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+	type testConfig struct {
+		Port  int
+		Debug bool `default:"true"`
+	}
+
+	got, err := Load[testConfig](FromSource(testSource{values: map[string]string{"port": "1"}}))
+	if err != nil {
+		t.Errorf("Load: unexpected error %v", err)
+	}
+	if want := (testConfig{Port: 1, Debug: true}); got != want {
+		t.Errorf("Load: got %+v, want %+v", got, want)
+	}
+
+	got, err = Load[testConfig](FromSource(testSource{values: map[string]string{"port": "x"}}))
+	var fieldErrs *FieldErrors
+	if !errors.As(err, &fieldErrs) || len(fieldErrs.Errors) != 1 {
+		t.Errorf("Load: expected a field error, got %v", err)
+	}
+	if want := (testConfig{Debug: true}); got != want {
+		t.Errorf("Load: got %+v, want %+v", got, want)
+	}
+
+	if _, err := Load[int](FromEnv()); err == nil {
+		t.Errorf("Load: expected error for a non-struct type, got none")
+	}
+	if _, err := NewHolder[*testConfig](FromEnv()); err == nil {
+		t.Errorf("NewHolder: expected error for a non-struct type, got none")
+	}
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Origin describes where a single value came from.
+type Origin struct {
+	// Source names the source, e.g. file[dev.config], env or default.
+	Source string
+	// Line is the line the value was defined on within the source, or 0 if unknown.
+	Line int
+}
+
+func (o Origin) String() string {
+	if o.Line == 0 {
+		return o.Source
+	}
+	return fmt.Sprintf("%v:%v", o.Source, o.Line)
+}
+
+// Provenance describes where the value of a key came from.
+// It never holds the value itself, to prevent accidental logging of secrets.
+type Provenance struct {
+	Key string
+	// Origin is where the value in effect came from.
+	Origin Origin
+	// Overridden are the earlier origins of the key whose values were overridden, in the order they were merged.
+	Overridden []Origin
+}
+
+// Explain returns where the value of every key in the current config state came from, sorted by key.
+// Defaults from struct tags are only included after To has been called.
+func (c *Builder) Explain() []Provenance {
+	var explained []Provenance
+	for key, origins := range c.origins {
+		last := len(origins) - 1
+		explained = append(explained, Provenance{
+			Key:        key,
+			Origin:     origins[last],
+			Overridden: append([]Origin(nil), origins[:last]...),
+		})
+	}
+	for key := range c.defaulted {
+		explained = append(explained, Provenance{Key: key, Origin: Origin{Source: defaultSource}})
+	}
+	sort.Slice(explained, func(i, j int) bool {
+		return explained[i].Key < explained[j].Key
+	})
+	return explained
+}
+
+// lineSource is implemented by sources that know the line each key was defined on.
+type lineSource interface {
+	Source
+	// valuesWithLines is Values, along with the line of each key.
+	valuesWithLines() (values map[string]string, lines map[string]int, err error)
+}
+
+// source returns the name of the source the value of key came from, if any.
+func (c *Builder) source(key string) string {
+	origins := c.origins[key]
+	if len(origins) == 0 {
+		return ""
+	}
+	return origins[len(origins)-1].Source
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuilder_Explain(t *testing.T) {
+	t.Parallel()
+	type testConfig struct {
+		A int
+		B string
+		C string `default:"c"`
+		D string `default:"d"`
+	}
+
+	file, err := ioutil.TempFile("", "testexplain")
+	if err != nil {
+		t.Fatalf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	if _, err = file.Write([]byte(strings.Join([]string{"# comment", "A=1", "B=2", "D=4"}, "\n"))); err != nil {
+		t.Fatalf("failed to write test data to temp file: %v", err)
+	}
+
+	fileName := "file[" + file.Name() + "]"
+	want := []Provenance{
+		{Key: "a", Origin: Origin{Source: "config.testSource"}, Overridden: []Origin{{Source: fileName, Line: 2}}},
+		{Key: "b", Origin: Origin{Source: fileName, Line: 3}},
+		{Key: "c", Origin: Origin{Source: defaultSource}},
+		{Key: "d", Origin: Origin{Source: fileName, Line: 4}},
+	}
+
+	var got testConfig
+	builder := From(file.Name()).FromSource(testSource{values: map[string]string{"A": "3"}})
+	if err := builder.To(&got); err != nil {
+		t.Fatalf("Explain: unexpected error %v", err)
+	}
+	if got := builder.Explain(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Explain() = %+v, want %+v", got, want)
+	}
+}
+
+func TestOrigin_String(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		origin Origin
+		want   string
+	}{
+		{origin: Origin{Source: "env"}, want: "env"},
+		{origin: Origin{Source: "file[dev.config]", Line: 3}, want: "file[dev.config]:3"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.want, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.origin.String(); got != tt.want {
+				t.Errorf("Origin.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
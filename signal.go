@@ -0,0 +1,32 @@
+package config
+
+import (
+	"os"
+	"os/signal"
+	"sync/atomic"
+)
+
+// ReloadOnSignal binds target and stores it in v, then rebinds each time one of sigs is received, until Stop is called.
+// If no signals are given, SIGHUP is used, which is how operators conventionally ask a process to reload.
+// Each time, the sources are read again and bound to a new zero value of the type target points to,
+// the same as Watch. If binding succeeds and the result differs from what v holds, it is stored in v.
+// v always holds a pointer of the same type as target, and failed bindings are never stored, see Watcher.Err.
+//
+// If the initial binding of target fails, nothing is stored, the error is returned, and no signals are handled.
+// It panics if target is not a struct pointer, or if no signals are given on a platform without SIGHUP, i.e. js.
+func (c *Builder) ReloadOnSignal(v *atomic.Value, target interface{}, sigs ...os.Signal) (*Watcher, error) {
+	t := structPtrType(target, "ReloadOnSignal")
+	if len(sigs) == 0 {
+		sigs = reloadSignals
+	}
+	if len(sigs) == 0 {
+		panic("config: ReloadOnSignal(v, target, sigs) must be given signals on this platform")
+	}
+	if err := c.To(target); err != nil {
+		return nil, err
+	}
+	v.Store(target)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, sigs...)
+	return c.watch(t, target, nil, nil, signals, func() { signal.Stop(signals) }, v.Store), nil
+}
@@ -0,0 +1,12 @@
+//go:build !js
+// +build !js
+
+package config
+
+import (
+	"os"
+	"syscall"
+)
+
+// reloadSignals are the signals ReloadOnSignal handles by default.
+var reloadSignals = []os.Signal{syscall.SIGHUP}
@@ -0,0 +1,7 @@
+package config
+
+import "os"
+
+// reloadSignals are the signals ReloadOnSignal handles by default.
+// js has no SIGHUP, so signals must always be given.
+var reloadSignals []os.Signal
@@ -0,0 +1,75 @@
+//go:build !windows && !js && !plan9
+// +build !windows,!js,!plan9
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBuilder_ReloadOnSignal(t *testing.T) {
+	// cannot be Parallelized as it signals the whole process.
+	type testConfig struct {
+		Port int
+	}
+
+	file, err := ioutil.TempFile("", "testsignal")
+	if err != nil {
+		t.Fatalf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	write := func(content string) {
+		if err := ioutil.WriteFile(file.Name(), []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write test data to temp file: %v", err)
+		}
+	}
+	// signal sends SIGUSR1 to the process, and waits until done reports true.
+	signal := func(done func() bool) {
+		if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+			t.Fatalf("failed to signal: %v", err)
+		}
+		deadline := time.After(5 * time.Second)
+		for !done() {
+			select {
+			case <-deadline:
+				t.Fatalf("ReloadOnSignal: timed out waiting for reload")
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}
+
+	var v atomic.Value
+	var c testConfig
+
+	write("PORT=x")
+	if w, err := From(file.Name()).ReloadOnSignal(&v, &c, syscall.SIGUSR1); err == nil || w != nil || v.Load() != nil {
+		t.Fatalf("ReloadOnSignal: initial binding should have failed without storing, got %v", v.Load())
+	}
+
+	write("PORT=1")
+	w, err := From(file.Name()).ReloadOnSignal(&v, &c, syscall.SIGUSR1)
+	if err != nil {
+		t.Fatalf("ReloadOnSignal: unexpected error %v", err)
+	}
+	defer w.Stop()
+	if got := v.Load().(*testConfig); got != &c || c.Port != 1 {
+		t.Errorf("ReloadOnSignal: should have stored target, got %+v", got)
+	}
+
+	write("PORT=2")
+	signal(func() bool { return v.Load().(*testConfig).Port == 2 })
+	if c.Port != 1 {
+		t.Errorf("ReloadOnSignal: target should only be bound initially, got %+v", c)
+	}
+
+	write("PORT=x")
+	signal(func() bool { return w.Err() != nil })
+	if got := v.Load().(*testConfig); got.Port != 2 {
+		t.Errorf("ReloadOnSignal: failed bindings should not be stored, got %+v", got)
+	}
+}
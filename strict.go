@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Strict returns a new Builder that reports unknown keys.
+func Strict() *Builder {
+	return newBuilder().Strict()
+}
+
+// Strict makes To report every key that did not match a field as an UnknownKey, returning the Builder.
+// Keys from the environment are only checked if they were scoped with FromEnvWithPrefix,
+// as the environment is shared with everything else in the process.
+// Where a key is close to a known one, the error suggests it, e.g. DATABSE_URL: did you mean database_url?
+func (c *Builder) Strict() *Builder {
+	c.strict = true
+	return c
+}
+
+// checkUnknownKeys reports every checked key that was not used while populating a struct.
+func (c *Builder) checkUnknownKeys() {
+	var unknown []string
+	for key := range c.checked {
+		if _, ok := c.configMap[key]; ok && !c.used[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+
+	for _, key := range unknown {
+		fe := &FieldError{Key: key, Source: c.source(key), Kind: UnknownKey}
+		if suggestions := c.suggest(key); suggestions != nil {
+			fe.Err = fmt.Errorf("did you mean %v?", strings.Join(suggestions, " or "))
+		}
+		c.failedFields = append(c.failedFields, fe)
+	}
+}
+
+// maxSuggestions is the most known keys that suggest returns.
+const maxSuggestions = 3
+
+// suggest returns the known keys closest to key, if any are close enough to be a likely typo.
+func (c *Builder) suggest(key string) []string {
+	maxDistance := len(key) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+	distances := make(map[string]int)
+	var suggestions []string
+	for known := range c.known {
+		if d := editDistance(key, known); d <= maxDistance {
+			distances[known] = d
+			suggestions = append(suggestions, known)
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if distances[suggestions[i]] != distances[suggestions[j]] {
+			return distances[suggestions[i]] < distances[suggestions[j]]
+		}
+		return suggestions[i] < suggestions[j]
+	})
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+	return suggestions
+}
+
+// editDistance returns the optimal string alignment distance between a and b,
+// i.e. the fewest single character insertions, deletions, substitutions or transpositions of adjacent characters
+// that turn a into b, without editing any substring more than once. A swap, e.g. prot for port, is a single edit.
+func editDistance(a, b string) int {
+	var prev2 []int
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr := make([]int, len(b)+1)
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && prev2[j-2]+1 < curr[j] {
+				curr[j] = prev2[j-2] + 1
+			}
+		}
+		prev2, prev = prev, curr
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_editDistance(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "abc", b: "", want: 3},
+		{a: "", b: "abc", want: 3},
+		{a: "abc", b: "abc", want: 0},
+		{a: "kitten", b: "sitting", want: 3},
+		{a: "databse_url", b: "database_url", want: 1},
+		{a: "database_rul", b: "database_url", want: 1},
+		{a: "prot", b: "port", want: 1},
+		{a: "ca", b: "abc", want: 3}, // substrings are not edited twice
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			t.Parallel()
+			if got := editDistance(tt.a, tt.b); got != tt.want {
+				t.Errorf("editDistance() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Strict(t *testing.T) {
+	t.Parallel()
+	type upstream struct {
+		Host string
+	}
+	type testConfig struct {
+		DatabaseURL string `config:"DATABASE_URL"`
+		Required    string `config:",required"`
+		Password    string
+		Port        int
+		Labels      map[string]string
+		Upstreams   []upstream
+	}
+
+	values := map[string]string{
+		"DATABSE_URL":          "db://",
+		"DATABASE_URL":         "postgres://${DB_HOST}/app",
+		"DB_HOST":              "localhost", // only used by interpolation
+		"PASSWORD_FILE":        "nonexistfile",
+		"LABELS__TEAM":         "core",
+		"UPSTREAMS__0__HOST":   "a",
+		"UPSTREAMS__0__HOTS":   "b",
+		"UPSTREAMS__X__HOST":   "c",
+		"COMPLETELY_DIFFERENT": "x",
+		"REQUIERD":             "x",
+		"PROT":                 "1",
+	}
+	wantErrs := []string{
+		"config: completely_different: unknown key from config.testSource",
+		"config: databse_url: unknown key from config.testSource: did you mean database_url?",
+		"config: prot: unknown key from config.testSource: did you mean port?",
+		"config: requierd: unknown key from config.testSource: did you mean required?",
+		"config: upstreams__0__hots: unknown key from config.testSource: did you mean upstreams__0__host?",
+		"config: upstreams__x__host: unknown key from config.testSource: did you mean upstreams__0__host?",
+	}
+
+	var got testConfig
+	builder := FromSource(testSource{values: values}).WithInterpolation(false).Strict()
+	_ = builder.To(&got)
+
+	var gotErrs []string
+	for _, fe := range builder.failedFields {
+		if fe.Kind == UnknownKey {
+			gotErrs = append(gotErrs, fe.Error())
+		}
+	}
+	if !reflect.DeepEqual(gotErrs, wantErrs) {
+		t.Errorf("Strict: got %q, want %q", gotErrs, wantErrs)
+	}
+
+	builder = FromSource(testSource{values: values})
+	_ = builder.To(&got)
+	for _, fe := range builder.failedFields {
+		if fe.Kind == UnknownKey {
+			t.Errorf("Strict: unknown keys should only be reported in strict mode, got %v", fe)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Watcher reads the sources of a Builder again, periodically or on a signal, and reports when the config they bind to changes.
+type Watcher struct {
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+
+	mu  sync.Mutex
+	err error
+}
+
+// Watch starts polling the sources of the Builder every interval, until Stop is called.
+// Each time, the sources are read again and bound to a new zero value of the type target points to,
+// with the same options as the Builder, e.g. delimiters and decoders.
+// If binding succeeds and the result differs from the last one, onChange is called with a pointer to it.
+// Failed bindings are never delivered, see Err. Err reports on the initial binding, which changes are detected against,
+// until the first poll.
+//
+// Use default struct tags rather than pre-populating target, as each binding starts from the zero value.
+// target itself is not modified. It panics if target is not a struct pointer, or if interval is not positive.
+func (c *Builder) Watch(target interface{}, interval time.Duration, onChange func(interface{})) *Watcher {
+	t := structPtrType(target, "Watch")
+	if interval <= 0 {
+		panic("config: Watch(target, interval) interval must be positive")
+	}
+	last, err := c.rebind(t)
+	ticker := time.NewTicker(interval)
+	return c.watch(t, last, err, ticker.C, nil, ticker.Stop, onChange)
+}
+
+// watch starts a Watcher that rebinds the Builder to t on every tick or signal, calling onChange if the result differs from last.
+// err is the error of the binding that produced last, which Err reports until the first tick or signal.
+// cleanup is called once the Watcher stops.
+func (c *Builder) watch(t reflect.Type, last interface{}, err error, ticks <-chan time.Time, signals <-chan os.Signal, cleanup func(), onChange func(interface{})) *Watcher {
+	w := &Watcher{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+		err:  err,
+	}
+	go func() {
+		defer close(w.done)
+		defer cleanup()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticks:
+			case <-signals:
+			}
+			next, err := c.rebind(t)
+			w.mu.Lock()
+			w.err = err
+			w.mu.Unlock()
+			if err == nil && !reflect.DeepEqual(next, last) {
+				last = next
+				onChange(next)
+			}
+		}
+	}()
+	return w
+}
+
+// Err returns the error of the most recent binding, or nil if it succeeded.
+func (w *Watcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Stop stops polling, and waits for any call to onChange in progress to return.
+// It is safe to call more than once.
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
+
+// rebuild returns a new Builder with the same options as c, populated by reading the sources of c again.
+func (c *Builder) rebuild() *Builder {
+	b := newBuilder()
+	b.structDelim, b.sliceDelim = c.structDelim, c.sliceDelim
+	b.interpolate, b.interpolateEnv = c.interpolate, c.interpolateEnv
+	b.strict = c.strict
+	b.flagSet, b.flagArgs, b.flagsMerged = c.flagSet, c.flagArgs, c.flagsMerged
+	for t, fn := range c.decoders {
+		b.decoders[t] = fn
+	}
+	for _, s := range c.sources {
+		b.FromSource(s)
+	}
+	return b
+}
+
+// rebind rebuilds c and binds it to a new value of t, a struct type, returning a pointer to it.
+func (c *Builder) rebind(t reflect.Type) (interface{}, error) {
+	target := reflect.New(t).Interface()
+	if err := c.rebuild().To(target); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
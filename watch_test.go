@@ -0,0 +1,113 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestBuilder_Watch(t *testing.T) {
+	t.Parallel()
+	type testConfig struct {
+		Port  int
+		Debug bool `default:"true"`
+	}
+
+	file, err := ioutil.TempFile("", "testwatch")
+	if err != nil {
+		t.Fatalf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	write := func(content string) { // atomically, so that a partially written file is never read
+		if err := ioutil.WriteFile(file.Name()+".tmp", []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write test data to temp file: %v", err)
+		}
+		if err := os.Rename(file.Name()+".tmp", file.Name()); err != nil {
+			t.Fatalf("failed to rename temp file: %v", err)
+		}
+	}
+	write("PORT=1")
+
+	var c testConfig
+	builder := From(file.Name())
+	if err := builder.To(&c); err != nil {
+		t.Fatalf("Watch: unexpected error %v", err)
+	}
+
+	changes := make(chan *testConfig, 10)
+	w := builder.Watch(&c, 5*time.Millisecond, func(v interface{}) {
+		changes <- v.(*testConfig)
+	})
+	defer w.Stop()
+
+	select {
+	case got := <-changes:
+		t.Fatalf("Watch: should not report unchanged config, got %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	write("PORT=x") // fails to bind, so should not be delivered
+	deadline := time.After(5 * time.Second)
+	for w.Err() == nil {
+		select {
+		case got := <-changes:
+			t.Fatalf("Watch: should not report failed bindings, got %+v", got)
+		case <-deadline:
+			t.Fatalf("Watch: should have reported an error")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	write("PORT=2")
+	select {
+	case got := <-changes:
+		if want := (testConfig{Port: 2, Debug: true}); *got != want {
+			t.Errorf("Watch: got %+v, want %+v", *got, want)
+		}
+	case <-deadline:
+		t.Fatalf("Watch: should have reported a change")
+	}
+	if err := w.Err(); err != nil {
+		t.Errorf("Watch: unexpected error %v", err)
+	}
+	if c.Port != 1 {
+		t.Errorf("Watch: target should not be modified, got %+v", c)
+	}
+
+	w.Stop()
+	w.Stop() // safe to call more than once
+	write("PORT=3")
+	select {
+	case got := <-changes:
+		t.Errorf("Watch: should not report changes after Stop, got %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestBuilder_WatchInitialError(t *testing.T) {
+	t.Parallel()
+	type testConfig struct {
+		Port int
+	}
+
+	var c testConfig
+	w := FromSource(testSource{values: map[string]string{"PORT": "x"}}).Watch(&c, time.Hour, func(v interface{}) {
+		t.Errorf("Watch: should not report failed bindings, got %+v", v)
+	})
+	defer w.Stop()
+	if w.Err() == nil {
+		t.Errorf("Watch: should report the error of the initial binding before the first poll")
+	}
+}
+
+func TestBuilder_WatchShouldPanic(t *testing.T) {
+	t.Parallel()
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("should have caused a panic")
+		}
+	}()
+	var c struct{}
+	FromEnv().Watch(&c, 0, func(interface{}) {})
+}